@@ -0,0 +1,148 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// maxPublishReports is how many messages reportLog keeps publish results for
+const maxPublishReports = 1000
+
+// reportLog keeps the publish reports of the most recently bridged messages by message ID, including
+// the ones no relay accepted. Reports are kept in memory only.
+type reportLog struct {
+	mu      sync.Mutex
+	reports map[string]*publishReport
+	order   []string
+}
+
+// newReportLog creates an empty report log
+func newReportLog() *reportLog {
+	return &reportLog{reports: make(map[string]*publishReport)}
+}
+
+// add records the report for its message and the messages merged into it, forgetting the oldest
+// messages once the log is full
+func (l *reportLog) add(report *publishReport, mergedIDs []string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, id := range append([]string{report.MessageID}, mergedIDs...) {
+		if _, ok := l.reports[id]; !ok {
+			if len(l.order) >= maxPublishReports {
+				delete(l.reports, l.order[0])
+				l.order = l.order[1:]
+			}
+			l.order = append(l.order, id)
+		}
+		l.reports[id] = report
+	}
+}
+
+// get returns the last publish report of the message
+func (l *reportLog) get(messageID string) (*publishReport, bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	report, ok := l.reports[messageID]
+	return report, ok
+}
+
+// MessageStatus is the Nostr side of a Discord message as served by the API
+type MessageStatus struct {
+	MessageID string `json:"message_id"`
+	ChannelID string `json:"channel_id,omitempty"`
+	EventID   string `json:"event_id"`
+	RootID    string `json:"root_id,omitempty"`
+	// Bridged reports whether the message is in the event map, i.e. enough relays accepted its note
+	Bridged bool `json:"bridged"`
+	// Relays are the results of the last publish of the note, only known for messages bridged since
+	// the bridge started
+	Relays      []relayReport `json:"relays,omitempty"`
+	PublishedAt *time.Time    `json:"published_at,omitempty"`
+}
+
+// MessageStatus looks up the note of a Discord message in the event map and the recent publish
+// results, reporting false when the bridge never published it
+func (b *Bridge) MessageStatus(messageID string) (MessageStatus, bool) {
+	status := MessageStatus{MessageID: messageID}
+	bridged, inMap := b.events.Get(messageID)
+	report, reported := b.reports.get(messageID)
+	if !inMap && !reported {
+		return status, false
+	}
+
+	if inMap {
+		status.Bridged = true
+		status.EventID = bridged.EventID
+		status.RootID = bridged.RootID
+		status.ChannelID = bridged.ChannelID
+	}
+	if reported {
+		status.ChannelID = report.ChannelID
+		// A failed publish that isn't in the map still names the note that was attempted
+		if status.EventID == "" || status.EventID == report.EventID {
+			status.EventID = report.EventID
+			status.Relays = report.Relays
+			status.PublishedAt = &report.Timestamp
+		}
+	}
+	return status, true
+}
+
+// startAPIServer serves the bridge status and the status of bridged messages as JSON over HTTP on
+// addr until ctx is done
+func startAPIServer(ctx context.Context, b *Bridge, addr string) error {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on API address: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /status", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, b.Status())
+	})
+	mux.HandleFunc("GET /messages/{id}", func(w http.ResponseWriter, r *http.Request) {
+		status, ok := b.MessageStatus(r.PathValue("id"))
+		if !ok {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "message was not bridged"})
+			return
+		}
+		writeJSON(w, http.StatusOK, status)
+	})
+	mux.HandleFunc("GET /identities/{id}", func(w http.ResponseWriter, r *http.Request) {
+		identity, err := b.Identity(r.PathValue("id"))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, identity)
+	})
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("API server stopped: %v", err)
+		}
+	}()
+
+	log.Printf("API server listening on %s", listener.Addr())
+	return nil
+}
+
+// writeJSON writes the value as a JSON response with the status code
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,221 @@
+package bridge
+
+import (
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"slices"
+	"sync"
+)
+
+// authorKeys holds the Nostr identities of Discord users bridged under their own key: the users in
+// nostr.author_keys and, with nostr.author_seed, every other user with a key derived from the seed.
+// Derived keys are added to the key ring the first time a message of the user is bridged.
+type authorKeys struct {
+	seed string
+	ring *nostr.KeyRing
+
+	mu      sync.Mutex
+	pubkeys map[string]string // By Discord user ID
+	own     map[string]bool
+}
+
+// Identity is the Nostr identity a Discord user's messages are bridged under
+type Identity struct {
+	UserID string `json:"user_id"`
+	Pubkey string `json:"pubkey"`
+	Npub   string `json:"npub"`
+	// Shared is set when the user has no identity of their own and is bridged under nostr.pubkey
+	Shared bool `json:"shared"`
+}
+
+// newAuthorKeys creates the author identities from the config. Events of other pubkeys are signed
+// by fallback.
+func newAuthorKeys(config *utils.Config, fallback nostr.Signer) (*authorKeys, error) {
+	a := &authorKeys{
+		seed:    config.Nostr.AuthorSeed,
+		pubkeys: make(map[string]string, len(config.Nostr.AuthorKeys)),
+		own:     make(map[string]bool, len(config.Nostr.AuthorKeys)),
+	}
+	var err error
+	a.ring, err = nostr.NewKeyRing(fallback, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	for userID, privKey := range config.Nostr.AuthorKeys {
+		pubkey, err := a.ring.Add(privKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid nostr.author_keys key of user %s: %w", userID, err)
+		}
+		a.pubkeys[userID] = pubkey
+		a.own[pubkey] = true
+	}
+	// Deriving a key checks the seed up front instead of on the first message
+	if a.seed != "" {
+		if _, err := nostr.DeriveAuthorKey(a.seed, ""); err != nil {
+			return nil, fmt.Errorf("invalid nostr.author_seed: %w", err)
+		}
+	}
+	return a, nil
+}
+
+// pubkey returns the pubkey of the user's own identity to publish under, deriving it from the seed
+// and registering it when needed
+func (a *authorKeys) pubkey(userID string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if pubkey, ok := a.pubkeys[userID]; ok {
+		return pubkey, true
+	}
+	privKey, ok := a.derive(userID)
+	if !ok {
+		return "", false
+	}
+	pubkey, err := a.ring.Add(privKey)
+	if err != nil {
+		log.Printf("Error adding derived Nostr key of user %s: %v", userID, err)
+		return "", false
+	}
+	a.pubkeys[userID] = pubkey
+	a.own[pubkey] = true
+	log.Printf("Derived Nostr identity %s for Discord user %s", pubkey, userID)
+	return pubkey, true
+}
+
+// lookup returns the pubkey of the user's own identity like pubkey, but without registering a derived
+// key, so looking up users who were never bridged doesn't grow the key ring
+func (a *authorKeys) lookup(userID string) (string, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if pubkey, ok := a.pubkeys[userID]; ok {
+		return pubkey, true
+	}
+	privKey, ok := a.derive(userID)
+	if !ok {
+		return "", false
+	}
+	pubkey, err := nostr.DerivePublicKey(privKey)
+	if err != nil {
+		log.Printf("Error deriving Nostr pubkey of user %s: %v", userID, err)
+		return "", false
+	}
+	return pubkey, true
+}
+
+// derive returns the private key of the user derived from the seed, if there is one
+func (a *authorKeys) derive(userID string) (string, bool) {
+	if a.seed == "" {
+		return "", false
+	}
+	privKey, err := nostr.DeriveAuthorKey(a.seed, userID)
+	if err != nil {
+		log.Printf("Error deriving Nostr key of user %s: %v", userID, err)
+		return "", false
+	}
+	return privKey, true
+}
+
+// all returns the pubkeys of the identities known so far in a stable order. Derived identities are
+// only known once their user has been bridged.
+func (a *authorKeys) all() []string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	pubkeys := make([]string, 0, len(a.own))
+	for pubkey := range a.own {
+		pubkeys = append(pubkeys, pubkey)
+	}
+	slices.Sort(pubkeys)
+	return pubkeys
+}
+
+// has reports whether the pubkey belongs to one of the identities
+func (a *authorKeys) has(pubkey string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.own[pubkey]
+}
+
+// bridgePubkeys returns nostr.pubkey followed by the pubkeys of the known author identities
+func bridgePubkeys(config *utils.Config, authors *authorKeys) []string {
+	pubkeys := []string{config.Nostr.Pubkey}
+	if authors != nil {
+		pubkeys = append(pubkeys, authors.all()...)
+	}
+	return slices.Compact(pubkeys)
+}
+
+// setupAuthorKeys lets the bridge sign the notes of Discord users with their own keys, wrapping the
+// bridge's signer in a key ring that keeps signing everything else
+func (b *Bridge) setupAuthorKeys() error {
+	var err error
+	b.authors, err = newAuthorKeys(b.config, b.signer)
+	if err != nil {
+		return err
+	}
+	b.signer = b.authors.ring
+
+	if b.authors.seed != "" {
+		log.Printf("Bridging Discord users under Nostr keys derived from the author seed, %d with configured keys", len(b.config.Nostr.AuthorKeys))
+	} else {
+		log.Printf("Bridging %d Discord users under their own Nostr keys", len(b.config.Nostr.AuthorKeys))
+	}
+	return nil
+}
+
+// authorPubkey returns the pubkey notes of the Discord user are published under
+func (b *Bridge) authorPubkey(userID string) string {
+	if b.authors != nil {
+		if pubkey, ok := b.authors.pubkey(userID); ok {
+			return pubkey
+		}
+	}
+	return b.config.Nostr.Pubkey
+}
+
+// eventAuthor returns the pubkey the bridged event was published under
+func (b *Bridge) eventAuthor(event bridgedEvent) string {
+	if event.Pubkey != "" {
+		return event.Pubkey
+	}
+	return b.config.Nostr.Pubkey
+}
+
+// Identity returns the Nostr identity the Discord user's messages are bridged under
+func (b *Bridge) Identity(userID string) (Identity, error) {
+	pubkey := b.config.Nostr.Pubkey
+	if b.authors != nil {
+		if own, ok := b.authors.lookup(userID); ok {
+			pubkey = own
+		}
+	}
+	return newIdentity(userID, pubkey, b.config.Nostr.Pubkey)
+}
+
+// AuthorIdentity returns the Nostr identity the Discord user's messages are bridged under with the
+// config, without starting a bridge
+func AuthorIdentity(config *utils.Config, userID string) (Identity, error) {
+	if err := config.Prepare(); err != nil {
+		return Identity{}, err
+	}
+	authors, err := newAuthorKeys(config, nil)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	pubkey, ok := authors.lookup(userID)
+	if !ok {
+		pubkey = config.Nostr.Pubkey
+	}
+	return newIdentity(userID, pubkey, config.Nostr.Pubkey)
+}
+
+// newIdentity describes the identity of the user with the pubkey
+func newIdentity(userID, pubkey, sharedPubkey string) (Identity, error) {
+	npub, err := nostr.EncodeNpub(pubkey)
+	if err != nil {
+		return Identity{}, err
+	}
+	return Identity{UserID: userID, Pubkey: pubkey, Npub: npub, Shared: pubkey == sharedPubkey}, nil
+}
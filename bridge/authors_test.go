@@ -0,0 +1,38 @@
+package bridge
+
+import (
+	"ndmBridge/utils"
+	"strings"
+	"testing"
+)
+
+func testAuthorKeys(t *testing.T) *authorKeys {
+	t.Helper()
+	config := &utils.Config{}
+	config.Nostr.AuthorSeed = strings.Repeat("11", 32)
+	authors, err := newAuthorKeys(config, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authors
+}
+
+func TestAuthorKeysLookupDoesNotRegister(t *testing.T) {
+	authors := testAuthorKeys(t)
+
+	looked, ok := authors.lookup("123")
+	if !ok {
+		t.Fatal("lookup() found no identity with a seed")
+	}
+	if authors.has(looked) || len(authors.all()) != 0 {
+		t.Errorf("lookup() registered %s, want no identities until the user is bridged", looked)
+	}
+
+	bridged, ok := authors.pubkey("123")
+	if !ok || bridged != looked {
+		t.Errorf("pubkey() = %s, %v, want the looked up %s", bridged, ok, looked)
+	}
+	if !authors.has(bridged) || len(authors.all()) != 1 {
+		t.Errorf("pubkey() didn't register %s", bridged)
+	}
+}
@@ -0,0 +1,37 @@
+package bridge
+
+import (
+	"context"
+	"log"
+)
+
+// announceStart publishes the configured banner note to Nostr and posts the banner message in the
+// watched channels, so members know bridging is active. With sharding the note is only published by
+// shard 0 and each shard posts in the channels of its own guilds.
+func (b *Bridge) announceStart(ctx context.Context) {
+	banner := b.config.Banner
+
+	if banner.Nostr != "" && b.session.ShardID == 0 {
+		if event, _, err := b.publishNote(ctx, banner.Nostr, nil); err != nil {
+			log.Printf("Error publishing start banner note: %v", err)
+		} else {
+			log.Printf("Start banner note %s published", event.ID)
+		}
+	}
+
+	if banner.Discord == "" {
+		return
+	}
+	for _, channel := range b.config.Discord.Channels {
+		if found := lookupChannel(b.discord(), channel.ID); found != nil && !b.onShard(found.GuildID) {
+			continue
+		}
+		posted, err := b.session.ChannelMessageSend(channel.ID, banner.Discord)
+		if err != nil {
+			log.Printf("Error posting start banner in channel %s: %v", channel.ID, err)
+			continue
+		}
+		b.posted.add(posted.ID)
+	}
+	log.Println("Start banner posted in Discord")
+}
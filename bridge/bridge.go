@@ -0,0 +1,500 @@
+// Package bridge relays messages from Discord channels to Nostr relays.
+// The ndmBridge command is a thin wrapper around it, so it can be embedded in other Go programs.
+package bridge
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"os"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Options configures a Bridge
+type Options struct {
+	// Config holds the bridge settings, as returned by utils.LoadConfig or built in code
+	Config *utils.Config
+	// Session is an existing Discord session to attach to.
+	// When nil a session is created from the configured bot token and owned by the bridge.
+	Session *discordgo.Session
+}
+
+// Bridge relays Discord messages to Nostr and, optionally, Nostr replies back to Discord
+type Bridge struct {
+	config      *utils.Config
+	session     *discordgo.Session
+	ownsSession bool
+	signer      nostr.Signer
+	events      *eventMap
+	digest      *digest
+	scheduler   *scheduler
+	approvals   *approvalQueue
+	captions    *captionBuffer
+	posted      *postedMessages
+	reports     *reportLog
+	// mentionNames caches the names mentions are replaced with, nil when they are stripped
+	mentionNames *mentionNames
+	// authors are the identities of Discord users bridged under their own key, nil when there are none
+	authors   *authorKeys
+	blocked   []*regexp.Regexp
+	paused    atomic.Bool
+	startedAt time.Time
+	bridged   atomic.Int64
+
+	inFlight       sync.WaitGroup
+	removeHandlers []func()
+	cancel         context.CancelFunc // Stops the background features
+	publishCtx     context.Context
+	abortPublish   context.CancelFunc // Aborts publishes still running after the shutdown timeout
+}
+
+// New prepares a bridge from the options. The nostr package settings (publish limit, static and
+// client tags, AUTH) are process wide, so only one bridge should run per process.
+func New(opts Options) (*Bridge, error) {
+	if opts.Config == nil {
+		return nil, errors.New("bridge options must include a config")
+	}
+	config := opts.Config
+	if err := config.Prepare(); err != nil {
+		return nil, err
+	}
+
+	b := &Bridge{config: config, session: opts.Session, posted: newPostedMessages(), reports: newReportLog()}
+
+	// The remote signer already connects to a relay, so TLS settings must be in place first
+	if err := setupRelayConnections(config); err != nil {
+		return nil, err
+	}
+
+	if err := checkKeyOrder(config); err != nil {
+		return nil, err
+	}
+	var err error
+	b.signer, err = newSigner(config)
+	if err != nil {
+		return nil, fmt.Errorf("error setting up signer: %w", err)
+	}
+	if err := selfTest(b.signer, config.Nostr.Pubkey); err != nil {
+		return nil, fmt.Errorf("signing self-test failed: %w", err)
+	}
+	if len(config.Nostr.AuthorKeys) > 0 || config.Nostr.AuthorSeed != "" {
+		if err := b.setupAuthorKeys(); err != nil {
+			return nil, err
+		}
+	}
+
+	b.events, err = loadEventMap(config.Bridge.EventMapFile)
+	if err != nil {
+		return nil, fmt.Errorf("error loading event map: %w", err)
+	}
+
+	if b.session == nil {
+		// Create a new Discord session using the provided bot token.
+		b.session, err = discordgo.New("Bot " + config.Discord.Token)
+		if err != nil {
+			return nil, fmt.Errorf("error creating Discord session: %w", err)
+		}
+		b.ownsSession = true
+		if config.Discord.ShardCount > 0 {
+			b.session.ShardID = config.Discord.ShardID
+			b.session.ShardCount = config.Discord.ShardCount
+			log.Printf("Running as shard %d of %d", config.Discord.ShardID, config.Discord.ShardCount)
+		}
+		log.Println("Discord session created successfully")
+	}
+
+	// Prepare has already checked that the patterns compile
+	for _, pattern := range config.Content.BlockedPatterns {
+		b.blocked = append(b.blocked, regexp.MustCompile(pattern))
+	}
+
+	// Hold attachment-only messages briefly so a caption sent right after joins them
+	if config.Content.CaptionWindow > 0 {
+		b.captions = newCaptionBuffer(b, config.Content.CaptionWindow)
+	}
+	if config.Content.Mentions == utils.MentionsNames {
+		b.mentionNames = newMentionNames(config.Content.MentionCacheTTL)
+	}
+
+	nostr.SetMaxInFlight(config.Bridge.MaxInFlight)
+	nostr.SetQueueHighWater(config.Bridge.QueueHighWater)
+	nostr.SetRelayTimeout(config.Nostr.RelayTimeout)
+	nostr.SetPublishQuorum(config.Nostr.PublishQuorum)
+	nostr.SetPingInterval(config.Nostr.PingInterval)
+	nostr.SetMaxEventSize(config.Nostr.MaxEventSize)
+	nostr.SetRetryPolicy(nostr.RetryPolicy{
+		Attempts:         config.Nostr.Retry.Attempts,
+		Backoff:          config.Nostr.Retry.Backoff,
+		RetryableReasons: config.Nostr.Retry.RetryableReasons,
+	})
+	nostr.SetCircuitBreaker(nostr.CircuitBreaker{
+		Failures:    config.Nostr.CircuitBreaker.Failures,
+		Cooldown:    config.Nostr.CircuitBreaker.Cooldown,
+		MaxCooldown: config.Nostr.CircuitBreaker.MaxCooldown,
+	})
+	nostr.SetRelayPoW(config.Nostr.RelayPoW)
+	nostr.SetStaticTags(config.Nostr.StaticTags)
+	nostr.SetClientTag(!config.Nostr.DisableClientTag)
+	nostr.SetMonotonicCreatedAt(config.Nostr.MonotonicCreatedAt)
+	nostr.SetAuth(b.signer, config.Nostr.Pubkey, config.Nostr.AuthRelays)
+	if config.Nostr.RelayLimits != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), relayInfoTimeout)
+		nostr.LoadRelayLimits(ctx, config.Nostr.Relays, config.Nostr.RelayLimits == utils.RelayLimitsSkip)
+		cancel()
+	}
+
+	return b, nil
+}
+
+// Start registers the Discord handlers, opens the session when the bridge owns it and starts
+// the optional background features. They run until ctx is done or Stop is called.
+func (b *Bridge) Start(ctx context.Context) error {
+	b.publishCtx, b.abortPublish = context.WithCancel(ctx)
+	ctx, b.cancel = context.WithCancel(ctx)
+	b.startedAt = time.Now()
+
+	// Add the message handler, tracking in-flight handlers so Stop can wait for them
+	b.removeHandlers = append(b.removeHandlers, b.session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
+		b.inFlight.Add(1)
+		defer b.inFlight.Done()
+
+		log.Printf("New message received: %s", m.Content)
+		b.messageCreateHandler(b.publishCtx, liveSession{s}, m)
+	}))
+
+	// Mirror reactions on bridged messages as NIP-25 reactions when enabled
+	if b.config.Reactions.Enabled {
+		b.removeHandlers = append(b.removeHandlers, b.session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+			b.inFlight.Add(1)
+			defer b.inFlight.Done()
+
+			b.reactionAddHandler(b.publishCtx, liveSession{s}, r)
+		}))
+	}
+
+	// Bridge edits of bridged messages when an edit strategy is configured
+	if b.config.Edits.Strategy != "" {
+		b.removeHandlers = append(b.removeHandlers, b.session.AddHandler(func(s *discordgo.Session, m *discordgo.MessageUpdate) {
+			b.inFlight.Add(1)
+			defer b.inFlight.Done()
+
+			b.messageUpdateHandler(b.publishCtx, liveSession{s}, m)
+		}))
+	}
+
+	// Announce voice sessions and live stages in the configured channels
+	if len(b.config.Voice.Channels) > 0 {
+		va := newVoiceAnnouncer(b)
+		b.removeHandlers = append(b.removeHandlers, b.session.AddHandler(func(s *discordgo.Session, v *discordgo.VoiceStateUpdate) {
+			b.inFlight.Add(1)
+			defer b.inFlight.Done()
+
+			va.voiceStateUpdateHandler(b.publishCtx, liveSession{s}, v)
+		}), b.session.AddHandler(func(s *discordgo.Session, e *discordgo.StageInstanceEventCreate) {
+			b.inFlight.Add(1)
+			defer b.inFlight.Done()
+
+			va.stageInstanceCreateHandler(b.publishCtx, liveSession{s}, e)
+		}))
+	}
+
+	// Keep a NIP-51 bookmark set of each channel's pinned messages when enabled
+	if b.config.Pins.Enabled {
+		b.removeHandlers = append(b.removeHandlers, b.session.AddHandler(func(s *discordgo.Session, p *discordgo.ChannelPinsUpdate) {
+			b.inFlight.Add(1)
+			defer b.inFlight.Done()
+
+			b.channelPinsUpdateHandler(b.publishCtx, liveSession{s}, p)
+		}))
+	}
+
+	// Stage messages until a moderator approves them when moderation is enabled. The queue is created
+	// before the session opens so no message slips through unmoderated.
+	if b.config.Moderation.Emoji != "" {
+		b.approvals = startApprovals(ctx, b)
+		b.removeHandlers = append(b.removeHandlers, b.session.AddHandler(func(s *discordgo.Session, r *discordgo.MessageReactionAdd) {
+			b.inFlight.Add(1)
+			defer b.inFlight.Done()
+
+			b.approvals.reactionAddHandler(liveSession{s}, r)
+		}))
+	}
+
+	if b.ownsSession {
+		// Open a WebSocket connection to Discord
+		if err := b.session.Open(); err != nil {
+			b.removeAllHandlers()
+			return fmt.Errorf("error opening connection: %w", err)
+		}
+	}
+
+	// Collect messages into a daily summary instead of publishing each one
+	if b.config.Digest.Enabled {
+		b.digest = startDigest(ctx, b)
+	}
+
+	// Hold messages for the publish delay and outside the posting hours when configured
+	if b.config.Schedule.Delay > 0 || b.config.Schedule.Start != "" {
+		b.scheduler = startScheduler(ctx, b)
+	}
+
+	// Check the sign and publish path end to end before bridging anything
+	if b.config.Nostr.StartupTest.Relay != "" {
+		b.publishStartupTest(b.publishCtx)
+	}
+
+	// Bridge messages that were sent while the bot was offline
+	if b.config.Catchup.Enabled {
+		b.catchUp(b.publishCtx)
+	}
+
+	// Mirror Nostr replies back into Discord when enabled. Replies arrive from the relays rather than
+	// Discord, so with sharding only the first shard posts them.
+	if b.config.Reverse.Enabled && b.session.ShardID == 0 {
+		startReverseBridge(ctx, b)
+	} else if b.config.Reverse.Enabled {
+		log.Printf("Reverse bridge is left to shard 0, this is shard %d", b.session.ShardID)
+	}
+
+	// Keep the profile's about field in line with the channel topic
+	if b.config.Profile.TopicChannel != "" {
+		startProfileSync(ctx, b)
+	}
+
+	// Answer status queries from `ndmBridge status`
+	if b.config.Bridge.ControlSocket != "" {
+		if err := startControlServer(ctx, b, b.config.Bridge.ControlSocket); err != nil {
+			log.Printf("Error starting control server: %v", err)
+		}
+	}
+
+	// Serve message lookups for dashboards
+	if b.config.Bridge.APIAddr != "" {
+		if err := startAPIServer(ctx, b, b.config.Bridge.APIAddr); err != nil {
+			log.Printf("Error starting API server: %v", err)
+		}
+	}
+
+	// Let the community know bridging is active
+	if b.config.Banner.Nostr != "" || b.config.Banner.Discord != "" {
+		b.announceStart(b.publishCtx)
+	}
+
+	log.Println("Bridge is now running")
+	return nil
+}
+
+// Stop stops receiving messages, waits up to the shutdown timeout for in-flight events
+// and closes the relay connections
+func (b *Bridge) Stop() {
+	log.Println("Stopping bridge")
+
+	// Stop receiving new messages, then give in-flight events time to flush
+	b.removeAllHandlers()
+	if b.ownsSession {
+		b.session.Close()
+	}
+	if b.cancel != nil {
+		b.cancel()
+	}
+	drainInFlight(&b.inFlight, b.config.Bridge.ShutdownTimeout)
+	if b.abortPublish != nil {
+		b.abortPublish()
+	}
+
+	// Publish whatever the digest collected so far rather than dropping it
+	if b.digest != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), b.config.Bridge.ShutdownTimeout)
+		b.digest.flush(ctx)
+		cancel()
+	}
+	nostr.CloseRelays()
+
+	for step, stats := range nostr.ContentModifications() {
+		log.Printf("Content %s step modified %d messages, removing %d bytes", step, stats.Messages, stats.BytesRemoved)
+	}
+}
+
+// Pause stops bridging new Discord messages until Resume is called. Messages sent while paused are dropped.
+func (b *Bridge) Pause() {
+	b.paused.Store(true)
+	log.Println("Bridging paused")
+}
+
+// Resume continues bridging after Pause
+func (b *Bridge) Resume() {
+	b.paused.Store(false)
+	log.Println("Bridging resumed")
+}
+
+// QueueDepth returns how many events are waiting to be published or being published
+func (b *Bridge) QueueDepth() int {
+	return nostr.QueueDepth()
+}
+
+// Paused reports whether bridging is paused
+func (b *Bridge) Paused() bool {
+	return b.paused.Load()
+}
+
+// removeAllHandlers unregisters the Discord handlers added by Start
+func (b *Bridge) removeAllHandlers() {
+	for _, remove := range b.removeHandlers {
+		remove()
+	}
+	b.removeHandlers = nil
+}
+
+// drainInFlight waits for in-flight handlers to finish or for the timeout to expire
+func drainInFlight(inFlight *sync.WaitGroup, timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		inFlight.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("All in-flight events flushed")
+	case <-time.After(timeout):
+		log.Printf("Shutdown timeout of %s reached, forcing exit", timeout)
+	}
+}
+
+// relayInfoTimeout bounds how long New waits for the NIP-11 documents of all relays
+const relayInfoTimeout = 15 * time.Second
+
+// remoteSignerConnectTimeout bounds how long New waits for the bunker to accept the connection
+const remoteSignerConnectTimeout = 2 * time.Minute
+
+// newTLSConfig returns the TLS configuration for relay connections, or nil for the defaults.
+// A configured CA file is trusted in addition to the system roots.
+func newTLSConfig(config *utils.Config) (*tls.Config, error) {
+	if config.Nostr.CAFile == "" && !config.Nostr.InsecureSkipTLS {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: config.Nostr.InsecureSkipTLS}
+	if config.Nostr.InsecureSkipTLS {
+		log.Println("TLS certificate verification of relays is disabled, only use this for testing")
+	}
+
+	if config.Nostr.CAFile != "" {
+		pem, err := os.ReadFile(config.Nostr.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no PEM certificates found in CA file %s", config.Nostr.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+// setupRelayConnections applies the TLS, connect timeout and credential settings used for every relay connection
+func setupRelayConnections(config *utils.Config) error {
+	tlsConfig, err := newTLSConfig(config)
+	if err != nil {
+		return fmt.Errorf("error setting up TLS: %w", err)
+	}
+	nostr.SetTLSConfig(tlsConfig)
+	nostr.SetConnectTimeout(config.Nostr.ConnectTimeout)
+	nostr.SetFrameLogging(config.Nostr.LogFrames != "", config.Nostr.LogFrames == utils.LogFramesHex)
+
+	credentials := make(map[string]nostr.BasicAuth, len(config.Nostr.RelayAuth))
+	for relayURL, auth := range config.Nostr.RelayAuth {
+		credentials[relayURL] = nostr.BasicAuth{Username: auth.Username, Password: auth.Password}
+	}
+	nostr.SetRelayCredentials(credentials)
+	return nil
+}
+
+// newSigner returns a NIP-46 remote signer when a bunker is configured, otherwise the local key
+func newSigner(config *utils.Config) (nostr.Signer, error) {
+	if config.Nostr.BunkerURL == "" {
+		return nostr.NewKeySigner(config.Nostr.PrivKey)
+	}
+
+	rs, err := nostr.NewRemoteSigner(config.Nostr.BunkerURL, config.Nostr.BunkerClientKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerConnectTimeout)
+	defer cancel()
+	pubkey, err := rs.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if pubkey != config.Nostr.Pubkey {
+		return nil, fmt.Errorf("remote signer signs for %s but nostr.pubkey is %s", pubkey, config.Nostr.Pubkey)
+	}
+	log.Println("Using remote signer for events")
+
+	return rs, nil
+}
+
+// checkKeyOrder detects the common mistake of pasting the private key into nostr.pubkey, either with
+// the keys swapped or the private key in both fields, and explains it instead of failing the self-test
+func checkKeyOrder(config *utils.Config) error {
+	pubkey, privkey := config.Nostr.Pubkey, config.Nostr.PrivKey
+	if privkey == "" {
+		return nil
+	}
+
+	if pubkey == privkey {
+		if derived, err := nostr.DerivePublicKey(privkey); err == nil {
+			return fmt.Errorf("nostr.pubkey holds the private key, set it to the public key %s", derived)
+		}
+		return fmt.Errorf("nostr.pubkey and nostr.privkey are the same, nostr.pubkey must be the public key")
+	}
+
+	// A public key is also valid as a private key, so check whether it derives the configured privkey
+	if derived, err := nostr.DerivePublicKey(pubkey); err == nil && derived == privkey {
+		return fmt.Errorf("nostr.pubkey and nostr.privkey appear to be swapped, put the private key in nostr.privkey and the public key in nostr.pubkey")
+	}
+	return nil
+}
+
+// selfTest signs a dummy event and verifies it against the configured pubkey, so a key mismatch is
+// reported at startup instead of as rejections from every relay
+func selfTest(signer nostr.Signer, pubkey string) error {
+	if ks, ok := signer.(*nostr.KeySigner); ok && ks.PublicKey() != pubkey {
+		return fmt.Errorf("nostr.privkey belongs to pubkey %s but nostr.pubkey is %s", ks.PublicKey(), pubkey)
+	}
+
+	event := &nostr.NostrEvent{Pubkey: pubkey, CreatedAt: time.Now().Unix(), Kind: 1, Tags: [][]string{}, Content: "ndmBridge self-test"}
+	eventStr, err := nostr.SerializeEventForID(*event)
+	if err != nil {
+		return err
+	}
+	event.ID = nostr.ComputeEventID(eventStr)
+
+	ctx, cancel := context.WithTimeout(context.Background(), remoteSignerConnectTimeout)
+	defer cancel()
+	if err := signer.SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to sign test event, check the nostr.privkey format: %w", err)
+	}
+	if err := nostr.VerifyEvent(*event); err != nil {
+		return fmt.Errorf("test event doesn't verify, check that nostr.pubkey is the hex key matching the signer: %w", err)
+	}
+
+	log.Println("Signing self-test passed")
+	return nil
+}
@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxCaptionLength is the longest text message still treated as the caption of a preceding attachment
+const maxCaptionLength = 280
+
+// captionBuffer briefly holds attachment-only messages, so a caption their author sends right after
+// is bridged together with the attachments as one note
+type captionBuffer struct {
+	bridge *Bridge
+	window time.Duration
+
+	mu      sync.Mutex
+	pending map[string]*heldMessage
+}
+
+// heldMessage is an attachment-only message waiting for a caption
+type heldMessage struct {
+	ctx     context.Context
+	session discordSession
+	message *discordgo.MessageCreate
+	timer   *time.Timer
+}
+
+// newCaptionBuffer creates a buffer that waits up to window for captions
+func newCaptionBuffer(b *Bridge, window time.Duration) *captionBuffer {
+	return &captionBuffer{bridge: b, window: window, pending: make(map[string]*heldMessage)}
+}
+
+// hold takes over the message when it is held for a caption or is the caption of a held message,
+// and reports false when the caller should bridge it as usual. A held message followed by anything
+// but a caption is bridged first, so notes keep the Discord order.
+func (c *captionBuffer) hold(ctx context.Context, s discordSession, m *discordgo.MessageCreate) bool {
+	key := m.ChannelID + "/" + m.Author.ID
+
+	c.mu.Lock()
+	held := c.pending[key]
+	delete(c.pending, key)
+	if held != nil && !held.timer.Stop() {
+		// The window just ran out and the timer is already bridging it alone
+		held = nil
+	}
+	if held == nil {
+		if !isAttachmentOnly(m) {
+			c.mu.Unlock()
+			return false
+		}
+		c.pending[key] = c.newHeld(ctx, s, m, key)
+		c.mu.Unlock()
+		log.Printf("Holding attachment-only message %s for up to %s in case a caption follows", m.ID, c.window)
+		return true
+	}
+	c.mu.Unlock()
+	defer c.bridge.inFlight.Done()
+
+	if isCaption(m) {
+		log.Printf("Merging caption %s into attachment-only message %s", m.ID, held.message.ID)
+		merged := *m.Message
+		merged.Attachments = append(slices.Clone(held.message.Attachments), m.Attachments...)
+		c.bridge.bridgeMessage(ctx, s, &discordgo.MessageCreate{Message: &merged}, []string{held.message.ID})
+		return true
+	}
+
+	c.bridge.bridgeMessage(held.ctx, held.session, held.message, nil)
+	return c.hold(ctx, s, m)
+}
+
+// newHeld holds the message until the window runs out, then bridges it alone.
+// Held messages count as in flight so Stop waits for them.
+func (c *captionBuffer) newHeld(ctx context.Context, s discordSession, m *discordgo.MessageCreate, key string) *heldMessage {
+	c.bridge.inFlight.Add(1)
+	held := &heldMessage{ctx: ctx, session: s, message: m}
+	held.timer = time.AfterFunc(c.window, func() {
+		defer c.bridge.inFlight.Done()
+
+		c.mu.Lock()
+		if c.pending[key] == held {
+			delete(c.pending, key)
+		}
+		c.mu.Unlock()
+
+		log.Printf("No caption followed message %s, bridging it alone", m.ID)
+		c.bridge.bridgeMessage(ctx, s, m, nil)
+	})
+	return held
+}
+
+// isAttachmentOnly reports whether the message has attachments but no text, and doesn't reply to another message
+func isAttachmentOnly(m *discordgo.MessageCreate) bool {
+	return len(m.Attachments) > 0 && strings.TrimSpace(m.Content) == "" && m.MessageReference == nil
+}
+
+// isCaption reports whether the message is a short text without attachments that isn't a reply
+func isCaption(m *discordgo.MessageCreate) bool {
+	text := strings.TrimSpace(m.Content)
+	return len(m.Attachments) == 0 && text != "" && utf8.RuneCountInString(text) <= maxCaptionLength && m.MessageReference == nil
+}
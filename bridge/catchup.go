@@ -0,0 +1,52 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"sort"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// catchUp bridges messages sent to the watched channels while the bot was offline
+func (b *Bridge) catchUp(ctx context.Context) {
+	for _, channel := range b.config.Discord.Channels {
+		if found := lookupChannel(b.discord(), channel.ID); found != nil && !b.onShard(found.GuildID) {
+			log.Printf("Skipping catch-up for channel %s, its guild belongs to another shard", channel.ID)
+			continue
+		}
+		b.catchUpChannel(ctx, channel.ID)
+	}
+}
+
+// catchUpChannel bridges missed messages of one channel.
+// Only messages newer than the last bridged message and missing from the event map are published.
+func (b *Bridge) catchUpChannel(ctx context.Context, channelID string) {
+	lastID := b.events.LastMessageID(channelID)
+	if lastID == "" {
+		log.Printf("Skipping catch-up for channel %s, no previously bridged message is recorded", channelID)
+		return
+	}
+
+	messages, err := b.session.ChannelMessages(channelID, b.config.Catchup.Limit, "", lastID, "")
+	if err != nil {
+		log.Printf("Error fetching history of channel %s for catch-up: %v", channelID, err)
+		return
+	}
+
+	// Bridge oldest first so notes keep the Discord order
+	sort.Slice(messages, func(i, j int) bool {
+		return snowflakeAfter(messages[j].ID, messages[i].ID)
+	})
+
+	bridged := 0
+	for _, msg := range messages {
+		if _, ok := b.events.Get(msg.ID); ok {
+			continue
+		}
+		msg.ChannelID = channelID
+		b.messageCreateHandler(ctx, b.discord(), &discordgo.MessageCreate{Message: msg})
+		bridged++
+	}
+	log.Printf("Catch-up processed %d missed messages in channel %s", bridged, channelID)
+}
@@ -0,0 +1,101 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Status is a snapshot of a running bridge, served by the control socket
+type Status struct {
+	StartedAt       time.Time           `json:"started_at"`
+	MessagesBridged int64               `json:"messages_bridged"`
+	Paused          bool                `json:"paused"`
+	QueueDepth      int                 `json:"queue_depth"`
+	Relays          []nostr.RelayStatus `json:"relays"`
+}
+
+// Status returns a snapshot of the bridge's activity and relay health
+func (b *Bridge) Status() Status {
+	return Status{
+		StartedAt:       b.startedAt,
+		MessagesBridged: b.bridged.Load(),
+		Paused:          b.Paused(),
+		QueueDepth:      nostr.QueueDepth(),
+		Relays:          nostr.RelayStatuses(),
+	}
+}
+
+// startControlServer serves the bridge status as JSON over HTTP on a unix socket until ctx is done
+func startControlServer(ctx context.Context, b *Bridge, path string) error {
+	// A socket left behind by a previous run would make Listen fail
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("failed to remove stale control socket: %w", err)
+	}
+
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return fmt.Errorf("failed to listen on control socket: %w", err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		listener.Close()
+		return fmt.Errorf("failed to restrict control socket permissions: %w", err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(b.Status())
+	})
+	server := &http.Server{Handler: mux}
+
+	go func() {
+		<-ctx.Done()
+		server.Close()
+		os.Remove(path)
+	}()
+	go func() {
+		if err := server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("Control server stopped: %v", err)
+		}
+	}()
+
+	log.Printf("Control server listening on %s", path)
+	return nil
+}
+
+// QueryStatus asks the bridge listening on the control socket for its status
+func QueryStatus(path string) (*Status, error) {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", path)
+			},
+		},
+	}
+
+	resp, err := client.Get("http://ndmbridge/status")
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach the bridge on %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to query status: %s", resp.Status)
+	}
+
+	var status Status
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, fmt.Errorf("failed to decode status: %w", err)
+	}
+	return &status, nil
+}
@@ -0,0 +1,126 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"ndmBridge/utils"
+	"strings"
+	"sync"
+	"time"
+)
+
+// digest buffers bridged messages and publishes them as one summary note per day
+type digest struct {
+	bridge *Bridge
+
+	mu      sync.Mutex
+	entries []digestEntry
+}
+
+// digestEntry is a prepared message waiting for the next summary
+type digestEntry struct {
+	author  string
+	content string
+}
+
+// startDigest creates the digest buffer and schedules the daily summary until ctx is done
+func startDigest(ctx context.Context, b *Bridge) *digest {
+	d := &digest{bridge: b}
+	go d.run(ctx)
+	log.Printf("Digest mode enabled, summary will be published daily at %s", b.config.Digest.Time)
+	return d
+}
+
+// add buffers a prepared message for the next summary
+func (d *digest) add(author, content string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.entries = append(d.entries, digestEntry{author: author, content: content})
+	log.Printf("Message added to digest, %d messages pending", len(d.entries))
+}
+
+// run publishes the summary every day at the configured time
+func (d *digest) run(ctx context.Context) {
+	for {
+		next := nextClockTime(time.Now(), d.bridge.config.Digest.Time)
+		log.Printf("Next digest scheduled for %s", next.Format(time.RFC3339))
+
+		select {
+		case <-time.After(time.Until(next)):
+			d.flush(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// flush publishes the buffered messages as a single note and clears the buffer
+func (d *digest) flush(ctx context.Context) {
+	d.mu.Lock()
+	entries := d.entries
+	d.entries = nil
+	d.mu.Unlock()
+
+	if len(entries) == 0 {
+		log.Println("No messages to include in digest")
+		return
+	}
+
+	content := fmt.Sprintf("Daily summary for %s\n\n%s", time.Now().Format("2006-01-02"), renderDigest(entries, d.bridge.config.Digest.Byline))
+
+	// A busy day can exceed the relay limits, so the summary is split into a chain of notes instead
+	// of being lost, whatever content.oversize says for single messages
+	parts := []string{content}
+	if !d.bridge.partsFit(parts, nil) {
+		parts = d.bridge.splitToFit(content, nil, len([]rune(content)))
+		if parts == nil {
+			log.Printf("Dropping digest with %d messages, it can't be split into notes small enough for the relays", len(entries))
+			return
+		}
+		log.Printf("Digest is too large for the relays, splitting it into %d notes", len(parts))
+	}
+
+	event, _, err := d.bridge.publishNote(ctx, parts[0], nil)
+	if err != nil {
+		log.Printf("Error publishing digest: %v", err)
+		return
+	}
+	d.bridge.bridged.Add(int64(len(entries)))
+	log.Printf("Digest with %d messages published", len(entries))
+	if len(parts) > 1 {
+		d.bridge.publishChain(ctx, d.bridge.config.Nostr.Pubkey, parts[1:], event.ID, event.ID, nil)
+	}
+}
+
+// renderDigest joins the entries into the summary text, placing the author bylines as configured.
+// With bylines once per burst, consecutive messages of an author share one paragraph.
+func renderDigest(entries []digestEntry, byline string) string {
+	var paragraphs []string
+	for i, entry := range entries {
+		switch {
+		case byline == utils.BylineNever:
+			paragraphs = append(paragraphs, entry.content)
+		case byline == utils.BylineBurst && i > 0 && entries[i-1].author == entry.author:
+			paragraphs[len(paragraphs)-1] += "\n" + entry.content
+		default:
+			paragraphs = append(paragraphs, fmt.Sprintf("%s: %s", entry.author, entry.content))
+		}
+	}
+	return strings.Join(paragraphs, "\n\n")
+}
+
+// nextClockTime returns the next occurrence of the HH:MM clock time after now
+func nextClockTime(now time.Time, clock string) time.Time {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		t = time.Time{}
+	}
+
+	next := time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), 0, 0, now.Location())
+	if !next.After(now) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
@@ -0,0 +1,105 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"strings"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// messageUpdateHandler bridges an edit of a bridged message as edits.strategy says: "repost"
+// replaces the note with a new one and requests deletion of the old one, "followup" replies to the
+// note with the updated text and "comment" publishes the updated text as a NIP-22 comment on it
+func (b *Bridge) messageUpdateHandler(ctx context.Context, s discordSession, m *discordgo.MessageUpdate) {
+	// Updates without an edit timestamp only add embeds or flags to the message
+	if m.Author == nil || m.EditedTimestamp == nil || m.Author.ID == s.cache().User.ID || b.posted.has(m.ID) {
+		return
+	}
+	if b.Paused() {
+		log.Printf("Bridging is paused, dropping edit of message %s", m.ID)
+		return
+	}
+	// With moderation, edited text needs approval just like new messages
+	if b.approvals != nil {
+		b.approvals.stageEdit(ctx, s, m)
+		return
+	}
+	b.bridgeEdit(ctx, s, m)
+}
+
+// bridgeEdit publishes the edit of a bridged message
+func (b *Bridge) bridgeEdit(ctx context.Context, s discordSession, m *discordgo.MessageUpdate) {
+	bridged, ok := b.events.Get(m.ID)
+	if !ok || bridged.EventID == "" {
+		return
+	}
+
+	if b.config.Edits.Strategy == utils.EditsRepost {
+		b.repostEdit(ctx, s, m, bridged)
+		return
+	}
+
+	channelConfig := b.watchedChannel(s, m.ChannelID)
+	if channelConfig == nil {
+		return
+	}
+	created := &discordgo.MessageCreate{Message: m.Message}
+	content, blocked := b.filterBlocked(nostr.PrepareMessageContent(created, b.contentOptions(s, created, channelConfig)))
+	if blocked && b.config.Content.BlockedAction == utils.BlockedSkip {
+		log.Printf("Skipping edit of message %s matching a blocked pattern", m.ID)
+		return
+	}
+	if strings.TrimSpace(content) == "" {
+		log.Printf("Skipping edit of message %s with nothing left to bridge", m.ID)
+		return
+	}
+
+	relayHint := b.config.RelaysForKind(1)[0]
+	author := b.authorPubkey(m.Author.ID)
+	var event *nostr.NostrEvent
+	var err error
+	if b.config.Edits.Strategy == utils.EditsComment {
+		event, err = nostr.CreateEvent(nostr.CommentKind, content, author, nostr.CommentTags(bridged.EventID, relayHint, b.eventAuthor(bridged)))
+	} else {
+		event, err = nostr.CreateNostrEvent("Edited:\n"+content, author, nostr.ReplyTags(bridged.threadRoot(), bridged.EventID, relayHint))
+	}
+	if err != nil {
+		log.Printf("Error creating Nostr event for edit of message %s: %v", m.ID, err)
+		return
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, event, b.signer, b.config.RelaysForKind(event.Kind)); err != nil {
+		log.Printf("Error sending edit of message %s: %v", m.ID, err)
+		return
+	}
+	log.Printf("Edit of message %s bridged as %s on note %s", m.ID, event.ID, bridged.EventID)
+}
+
+// repostEdit bridges the edited message as a new note and, once it was published, requests deletion
+// of the note it replaces with NIP-09
+func (b *Bridge) repostEdit(ctx context.Context, s discordSession, m *discordgo.MessageUpdate, old bridgedEvent) {
+	b.bridgeMessage(ctx, s, &discordgo.MessageCreate{Message: m.Message}, nil)
+
+	current, ok := b.events.Get(m.ID)
+	if !ok || current.EventID == old.EventID {
+		log.Printf("Keeping note %s of message %s, its edit was not published", old.EventID, m.ID)
+		return
+	}
+
+	// Only the author of a note can request its deletion
+	deletion, err := nostr.CreateEvent(nostr.DeletionKind, "", b.eventAuthor(old), [][]string{
+		{"e", old.EventID},
+		{"k", "1"},
+	})
+	if err != nil {
+		log.Printf("Error creating deletion request for note %s: %v", old.EventID, err)
+		return
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, deletion, b.signer, b.config.RelaysForKind(deletion.Kind)); err != nil {
+		log.Printf("Error requesting deletion of note %s: %v", old.EventID, err)
+		return
+	}
+	log.Printf("Edit of message %s reposted as %s, deletion of note %s requested", m.ID, current.EventID, old.EventID)
+}
@@ -0,0 +1,170 @@
+package bridge
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// eventMap maps Discord message IDs to Nostr event IDs, optionally persisted to a JSON file
+type eventMap struct {
+	mu   sync.Mutex
+	path string
+	data eventMapData
+}
+
+// eventMapData is the on-disk format of the event map
+type eventMapData struct {
+	LastMessageIDs map[string]string       `json:"last_message_ids"` // Newest bridged message per channel
+	Events         map[string]bridgedEvent `json:"events"`
+}
+
+// bridgedEvent is the Nostr side of a bridged Discord message
+type bridgedEvent struct {
+	EventID   string `json:"event_id"`
+	RootID    string `json:"root_id,omitempty"` // Root of the NIP-10 thread the event belongs to, empty for a root
+	ChannelID string `json:"channel_id,omitempty"`
+	Pubkey    string `json:"pubkey,omitempty"` // Author the event was published under, empty for nostr.pubkey
+}
+
+// threadRoot returns the root event ID of the thread this event belongs to
+func (e bridgedEvent) threadRoot() string {
+	if e.RootID != "" {
+		return e.RootID
+	}
+	return e.EventID
+}
+
+// loadEventMap reads the event map from path. An empty path keeps the map in memory only.
+func loadEventMap(path string) (*eventMap, error) {
+	em := &eventMap{path: path, data: eventMapData{
+		LastMessageIDs: make(map[string]string),
+		Events:         make(map[string]bridgedEvent),
+	}}
+	if path == "" {
+		return em, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		log.Printf("Event map %s does not exist yet, starting empty", path)
+		return em, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read event map: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &em.data); err != nil {
+		return nil, fmt.Errorf("cannot unmarshal event map: %w", err)
+	}
+	if em.data.LastMessageIDs == nil {
+		em.data.LastMessageIDs = make(map[string]string)
+	}
+	if em.data.Events == nil {
+		em.data.Events = make(map[string]bridgedEvent)
+	}
+	log.Printf("Event map loaded with %d entries", len(em.data.Events))
+
+	return em, nil
+}
+
+// Get returns the Nostr event bridged for the Discord message
+func (em *eventMap) Get(messageID string) (bridgedEvent, bool) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	event, ok := em.data.Events[messageID]
+	return event, ok
+}
+
+// FindMessage returns the Discord message ID and event that produced the Nostr event
+func (em *eventMap) FindMessage(eventID string) (string, bridgedEvent, bool) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	for messageID, event := range em.data.Events {
+		if event.EventID == eventID {
+			return messageID, event, true
+		}
+	}
+	return "", bridgedEvent{}, false
+}
+
+// LastMessageID returns the newest Discord message ID that was bridged from the channel
+func (em *eventMap) LastMessageID(channelID string) string {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+	return em.data.LastMessageIDs[channelID]
+}
+
+// Set records the Nostr event bridged for a Discord message posted in channelID and saves the map
+func (em *eventMap) Set(channelID, messageID string, event bridgedEvent) error {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	event.ChannelID = channelID
+	em.data.Events[messageID] = event
+	if snowflakeAfter(messageID, em.data.LastMessageIDs[channelID]) {
+		em.data.LastMessageIDs[channelID] = messageID
+	}
+
+	return em.save()
+}
+
+// addMissing records the events of messages that aren't in the map yet, keyed by message ID, and saves
+// the map once. It returns how many were added.
+func (em *eventMap) addMissing(events map[string]bridgedEvent) (int, error) {
+	em.mu.Lock()
+	defer em.mu.Unlock()
+
+	added := 0
+	for messageID, event := range events {
+		if _, ok := em.data.Events[messageID]; ok {
+			continue
+		}
+		em.data.Events[messageID] = event
+		if snowflakeAfter(messageID, em.data.LastMessageIDs[event.ChannelID]) {
+			em.data.LastMessageIDs[event.ChannelID] = messageID
+		}
+		added++
+	}
+
+	return added, em.save()
+}
+
+// save writes the map to a temporary file and renames it into place. The caller must hold em.mu.
+func (em *eventMap) save() error {
+	if em.path == "" {
+		return nil
+	}
+
+	data, err := json.Marshal(em.data)
+	if err != nil {
+		return fmt.Errorf("cannot marshal event map: %w", err)
+	}
+
+	tmp := em.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("cannot write event map: %w", err)
+	}
+	if err := os.Rename(tmp, em.path); err != nil {
+		return fmt.Errorf("cannot replace event map: %w", err)
+	}
+	return nil
+}
+
+// snowflakeAfter reports whether Discord snowflake a is newer than b. An empty b is older than anything.
+func snowflakeAfter(a, b string) bool {
+	if b == "" {
+		return true
+	}
+	ai, errA := strconv.ParseUint(a, 10, 64)
+	bi, errB := strconv.ParseUint(b, 10, 64)
+	if errA != nil || errB != nil {
+		return false
+	}
+	return ai > bi
+}
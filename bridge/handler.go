@@ -0,0 +1,531 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// messageCreateHandler handles incoming Discord messages, giving up on publishing when ctx is done
+func (b *Bridge) messageCreateHandler(ctx context.Context, s discordSession, m *discordgo.MessageCreate) {
+	// Messages that aren't bridged are dropped at receipt, before approval, the schedule or a
+	// caption can hold them. bridgeMessage checks again once they are released.
+	if !b.accepts(s, m) {
+		return
+	}
+	if b.approvals != nil {
+		b.approvals.stage(ctx, s, m)
+		return
+	}
+	b.releaseMessage(ctx, s, m)
+}
+
+// accepts reports whether a received message is bridged at all: it isn't the bridge's own, it was
+// sent in a watched channel or one of its threads, and bridging isn't paused
+func (b *Bridge) accepts(s discordSession, m *discordgo.MessageCreate) bool {
+	if m.Author == nil || m.Author.ID == s.cache().User.ID {
+		return false
+	}
+	if b.watchedChannel(s, m.ChannelID) == nil {
+		return false
+	}
+	if b.posted.has(m.ID) {
+		log.Printf("Ignoring message %s posted by the bridge", m.ID)
+		return false
+	}
+	if b.Paused() {
+		log.Printf("Bridging is paused, dropping message %s", m.ID)
+		return false
+	}
+	return true
+}
+
+// releaseMessage bridges a message that needs no approval or was approved, holding it for the
+// schedule when one is configured
+func (b *Bridge) releaseMessage(ctx context.Context, s discordSession, m *discordgo.MessageCreate) {
+	if b.scheduler != nil {
+		b.scheduler.add(ctx, s, m)
+		return
+	}
+	b.handleMessage(ctx, s, m)
+}
+
+// handleMessage bridges a message once it is due, holding attachment-only messages for their caption
+func (b *Bridge) handleMessage(ctx context.Context, s discordSession, m *discordgo.MessageCreate) {
+	if b.captions != nil && b.captions.hold(ctx, s, m) {
+		return
+	}
+	b.bridgeMessage(ctx, s, m, nil)
+}
+
+// bridgeMessage publishes a Discord message as a note. The messages in mergedIDs were merged into it,
+// so they are mapped to the same note.
+func (b *Bridge) bridgeMessage(ctx context.Context, s discordSession, m *discordgo.MessageCreate, mergedIDs []string) {
+	config := b.config
+
+	if m.Author.ID == s.cache().User.ID {
+		log.Println("Ignoring message from bot itself")
+		return
+	}
+	if b.posted.has(m.ID) {
+		log.Printf("Ignoring message %s posted by the bridge", m.ID)
+		return
+	}
+
+	if b.Paused() {
+		log.Printf("Bridging is paused, dropping message %s", m.ID)
+		return
+	}
+
+	if reason := skipForFlags(m.Flags, config); reason != "" {
+		log.Printf("Skipping message %s: %s", m.ID, reason)
+		return
+	}
+
+	// Messages pinging crowds of users are likely spam from a compromised account
+	if mentions := mentionCount(m); config.Content.MaxMentions > 0 && mentions > config.Content.MaxMentions {
+		log.Printf("Skipping message %s from %s with %d mentions, more than the maximum of %d", m.ID, m.Author.Username, mentions, config.Content.MaxMentions)
+		return
+	}
+
+	var tags [][]string
+	var parent *bridgedEvent
+	channelConfig := config.Channel(m.ChannelID)
+	if channelConfig == nil {
+		// Messages in threads of a watched channel are bridged with the thread name as subject
+		channel := lookupChannel(s, m.ChannelID)
+		if channel == nil || !channel.IsThread() || config.Channel(channel.ParentID) == nil {
+			return
+		}
+		channelConfig = config.Channel(channel.ParentID)
+		if channel.Name != "" {
+			tags = append(tags, []string{"subject", channel.Name})
+		}
+
+		// The first message of a forum post carries the post's forum tags as hashtags
+		if m.ID == channel.ID {
+			tags = append(tags, forumTags(s, channel)...)
+		}
+
+		// Threads started from a message share its ID, so that message is the thread root
+		if starter, ok := b.events.Get(channel.ID); ok {
+			parent = &starter
+		}
+	}
+
+	if channelConfig.RequiredRoleID != "" && !hasRole(s, m, channelConfig.RequiredRoleID) {
+		log.Printf("Ignoring message from %s without the required role", m.Author.Username)
+		return
+	}
+
+	// The channel's configured hashtags make its notes discoverable by topic
+	for _, hashtag := range channelConfig.AutoTags {
+		if !slices.ContainsFunc(tags, func(tag []string) bool { return tag[0] == "t" && tag[1] == hashtag }) {
+			tags = append(tags, []string{"t", hashtag})
+		}
+	}
+
+	// Discord replies to a bridged message become NIP-10 replies to its note
+	// A reply whose referenced message is gone is bridged as a standalone note
+	replyToDeleted := m.Type == discordgo.MessageTypeReply && m.MessageReference != nil && m.ReferencedMessage == nil
+	switch {
+	case replyToDeleted:
+		log.Printf("Message %s replies to deleted message %s, bridging it without threading", m.ID, m.MessageReference.MessageID)
+	case m.MessageReference != nil:
+		if referenced, ok := b.events.Get(m.MessageReference.MessageID); ok && referenced.EventID != "" {
+			parent = &referenced
+		}
+	}
+
+	rootID := ""
+	if parent != nil {
+		rootID = parent.threadRoot()
+		tags = append(tags, nostr.ReplyTags(rootID, parent.EventID, config.RelaysForKind(1)[0])...)
+	}
+
+	// Let supporting relays and clients drop the note once it expires (NIP-40)
+	var expirationTags [][]string
+	if config.Nostr.Expiration > 0 {
+		sent := m.Timestamp
+		if sent.IsZero() {
+			sent = time.Now()
+		}
+		expiresAt := sent.Add(config.Nostr.Expiration).Unix()
+		expirationTags = [][]string{{"expiration", strconv.FormatInt(expiresAt, 10)}}
+		tags = append(tags, expirationTags...)
+	}
+
+	// Mentioned Discord users with a known Nostr pubkey are notified through p tags
+	tags = append(tags, mentionTags(m, config.Nostr.MentionPubkeys)...)
+
+	if config.Nostr.AuthorTags {
+		tags = append(tags, authorTags(m)...)
+	}
+
+	content := nostr.PrepareMessageContent(m, b.contentOptions(s, m, channelConfig))
+	// Community rules can block words and phrases, skipping the message or redacting them
+	content, blocked := b.filterBlocked(content)
+	if blocked && config.Content.BlockedAction == utils.BlockedSkip {
+		log.Printf("Skipping message %s from %s matching a blocked pattern", m.ID, m.Author.Username)
+		return
+	}
+	if blocked {
+		log.Printf("Redacted blocked patterns in message %s from %s", m.ID, m.Author.Username)
+	}
+
+	// Attachment-only messages have no text left when attachments aren't bridged
+	if strings.TrimSpace(content) == "" {
+		log.Printf("Skipping message %s with nothing left to bridge", m.ID)
+		return
+	}
+	// Skip trivially short messages such as "lol" or a single emoji
+	if minLength := config.MinContentLength(channelConfig); contentLength(content, channelConfig) < minLength {
+		log.Printf("Skipping message %s shorter than %d characters", m.ID, minLength)
+		return
+	}
+
+	if replyToDeleted && config.Content.MarkDeletedReplies {
+		content = "(reply to a deleted message)\n" + content
+	}
+
+	// Linked Nostr notes become NIP-18 quotes of them
+	content, quoteTags := nostr.QuoteReferences(content)
+	if len(quoteTags) > 0 {
+		log.Printf("Message %s quotes %d Nostr notes", m.ID, len(quoteTags))
+		tags = append(tags, quoteTags...)
+	}
+	log.Printf("Prepared content for Nostr event: %s", content)
+
+	if b.digest != nil {
+		b.digest.add(m.Author.Username, content)
+		return
+	}
+
+	// Long messages are split into a chain of notes, each replying to the previous one
+	parts := b.fitContent(m.ID, content, tags)
+	if parts == nil {
+		return
+	}
+
+	author := b.authorPubkey(m.Author.ID)
+	event, results, err := b.publishNoteAs(ctx, author, parts[0], tags)
+	b.confirmPublish(s, m, err)
+	if event != nil {
+		report := newPublishReport(m, event.ID, results)
+		b.reports.add(report, mergedIDs)
+		if config.Bridge.WebhookURL != "" {
+			b.notifyWebhook(report)
+		}
+	}
+	switch {
+	case errors.Is(err, nostr.ErrSignFailed):
+		log.Printf("Error signing Nostr event, check the configured privkey: %v", err)
+	case errors.Is(err, nostr.ErrEventTooLarge):
+		log.Printf("Error sending Nostr event, it exceeds the size limits of every relay: %v", err)
+	case err != nil:
+		log.Printf("Error sending Nostr event: %v", err)
+	default:
+		log.Println("Nostr event sent successfully")
+		b.bridged.Add(1)
+		bridged := bridgedEvent{EventID: event.ID, RootID: rootID}
+		if author != config.Nostr.Pubkey {
+			bridged.Pubkey = author
+		}
+		for _, id := range append([]string{m.ID}, mergedIDs...) {
+			if err := b.events.Set(m.ChannelID, id, bridged); err != nil {
+				log.Printf("Error saving event map: %v", err)
+			}
+		}
+		if config.Discord.PostPermalink {
+			b.postPermalink(s, m, event.ID)
+		}
+		if len(parts) > 1 {
+			chainRoot := rootID
+			if chainRoot == "" {
+				chainRoot = event.ID
+			}
+			b.publishChain(ctx, author, parts[1:], chainRoot, event.ID, expirationTags)
+		}
+	}
+}
+
+// contentOptions returns how the text of a message in the channel is prepared for its note
+func (b *Bridge) contentOptions(s discordSession, m *discordgo.MessageCreate, channelConfig *utils.ChannelConfig) nostr.ContentOptions {
+	config := b.config
+	return nostr.ContentOptions{
+		StripInvisible:      config.Content.StripInvisible,
+		Prefix:              channelConfig.Prefix,
+		Suffix:              channelConfig.Suffix,
+		StripCodeFences:     config.Content.CodeBlocks == utils.CodeBlocksStrip,
+		IndentCodeBlocks:    config.Content.CodeBlocks == utils.CodeBlocksIndent,
+		SkipAttachments:     !config.BridgeAttachments(channelConfig),
+		MentionName:         b.mentionNameFunc(s, m),
+		UnwrapURLs:          config.Content.UnwrapURLs,
+		UnwrapTimeout:       config.Content.UnwrapTimeout,
+		AttachmentPrefix:    config.Content.AttachmentPrefix,
+		MaxAttachments:      config.Content.MaxAttachments,
+		BlockedDomains:      config.Content.BlockedDomains,
+		AttachmentSeparator: config.Content.AttachmentSeparator,
+	}
+}
+
+// permalinkRelayHints is the number of relays included as hints in permalinks, keeping links short
+const permalinkRelayHints = 3
+
+// postPermalink replies to the Discord message with an njump.me link to the note, encoded as a
+// nevent with relay hints. The reply doesn't ping the author.
+func (b *Bridge) postPermalink(s discordSession, m *discordgo.MessageCreate, eventID string) {
+	relays := b.config.RelaysForKind(1)
+	nevent, err := nostr.EncodeNevent(eventID, relays[:min(len(relays), permalinkRelayHints)])
+	if err != nil {
+		log.Printf("Error encoding permalink of event %s: %v", eventID, err)
+		return
+	}
+
+	posted, err := s.ChannelMessageSendComplex(m.ChannelID, &discordgo.MessageSend{
+		Content:         "https://njump.me/" + nevent,
+		Reference:       m.Reference(),
+		AllowedMentions: &discordgo.MessageAllowedMentions{},
+	})
+	if err != nil {
+		log.Printf("Error posting permalink for message %s: %v", m.ID, err)
+		return
+	}
+	b.posted.add(posted.ID)
+}
+
+// publishChain publishes the remaining parts of a split message, each as a NIP-10 reply to the one before.
+// It stops at the first part that fails so the chain never has gaps.
+func (b *Bridge) publishChain(ctx context.Context, pubkey string, parts []string, rootID, parentID string, extraTags [][]string) {
+	for i, part := range parts {
+		tags := append(nostr.ReplyTags(rootID, parentID, b.config.RelaysForKind(1)[0]), extraTags...)
+		event, _, err := b.publishNoteAs(ctx, pubkey, part, tags)
+		if err != nil {
+			log.Printf("Error sending part %d of %d of split message: %v", i+2, len(parts)+1, err)
+			return
+		}
+		parentID = event.ID
+	}
+	log.Printf("Split message published as %d notes", len(parts)+1)
+}
+
+// publishNote creates a kind-1 note with the given content and tags, signs it and sends it to the relays.
+// The event is returned even when publishing failed, along with the result of each relay.
+func (b *Bridge) publishNote(ctx context.Context, content string, tags [][]string) (*nostr.NostrEvent, []nostr.RelayResult, error) {
+	return b.publishNoteAs(ctx, b.config.Nostr.Pubkey, content, tags)
+}
+
+// publishNoteAs is publishNote for a note published under the given pubkey
+func (b *Bridge) publishNoteAs(ctx context.Context, pubkey, content string, tags [][]string) (*nostr.NostrEvent, []nostr.RelayResult, error) {
+	event, err := nostr.CreateNostrEvent(content, pubkey, tags)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating Nostr event: %w", err)
+	}
+	log.Printf("Nostr event created: %+v", event)
+
+	results, err := nostr.SignAndSendEvent(ctx, event, b.signer, b.config.RelaysForKind(event.Kind))
+	return event, results, err
+}
+
+// confirmPublish reacts to the Discord message with the configured success or failure emoji
+func (b *Bridge) confirmPublish(s discordSession, m *discordgo.MessageCreate, publishErr error) {
+	emoji := b.config.Discord.SuccessReaction
+	if publishErr != nil {
+		emoji = b.config.Discord.FailureReaction
+	}
+	if emoji == "" {
+		return
+	}
+
+	if err := s.MessageReactionAdd(m.ChannelID, m.ID, emoji); err != nil {
+		log.Printf("Error adding %s reaction to message %s: %v", emoji, m.ID, err)
+	}
+}
+
+// skipForFlags returns why a message with the given flags shouldn't be bridged, or "" to bridge it.
+// Ephemeral messages and interaction placeholders are never bridged since nobody else sees them.
+func skipForFlags(flags discordgo.MessageFlags, config *utils.Config) string {
+	switch {
+	case flags&discordgo.MessageFlagsEphemeral != 0:
+		return "ephemeral message"
+	case flags&discordgo.MessageFlagsLoading != 0:
+		return "interaction response still loading"
+	case config.Content.SkipSilent && flags&discordgo.MessageFlagsSuppressNotifications != 0:
+		return "silent message"
+	case config.Content.SkipSuppressedEmbeds && flags&discordgo.MessageFlagsSuppressEmbeds != 0:
+		return "message with suppressed embeds"
+	}
+	return ""
+}
+
+// redactedText replaces the matches of blocked patterns
+const redactedText = "[redacted]"
+
+// filterBlocked reports whether the content matches a blocked pattern or links a blocked domain and,
+// when redacting, returns it with the matches replaced
+func (b *Bridge) filterBlocked(content string) (string, bool) {
+	redact := b.config.Content.BlockedAction == utils.BlockedRedact
+	blocked := false
+	for _, re := range b.blocked {
+		if !re.MatchString(content) {
+			continue
+		}
+		blocked = true
+		if redact {
+			content = re.ReplaceAllLiteralString(content, redactedText)
+		}
+	}
+
+	if b.config.Content.BlockedDomainsInText {
+		content = linkRe.ReplaceAllStringFunc(content, func(link string) string {
+			if !nostr.OnBlockedDomain(link, b.config.Content.BlockedDomains) {
+				return link
+			}
+			blocked = true
+			if redact {
+				return redactedText
+			}
+			return link
+		})
+	}
+	return content, blocked
+}
+
+// linkRe matches http and https links in message text
+var linkRe = regexp.MustCompile(`https?://\S+`)
+
+// mentionCount returns the number of users and roles the message mentions, counting @everyone and @here as one
+func mentionCount(m *discordgo.MessageCreate) int {
+	count := len(m.Mentions) + len(m.MentionRoles)
+	if m.MentionEveryone {
+		count++
+	}
+	return count
+}
+
+// contentLength counts the characters of prepared content, leaving out the channel's prefix and suffix
+// so they don't make a trivial message look long enough
+func contentLength(content string, channel *utils.ChannelConfig) int {
+	if channel.Prefix != "" {
+		content = strings.TrimPrefix(content, channel.Prefix+" ")
+	}
+	if channel.Suffix != "" {
+		if i := strings.LastIndex(content, " "+channel.Suffix); i >= 0 {
+			content = content[:i] + content[i+len(channel.Suffix)+1:]
+		}
+	}
+	return utf8.RuneCountInString(strings.TrimSpace(content))
+}
+
+// mentionTags returns a p tag for every mentioned user that has a pubkey in the mapping
+func mentionTags(m *discordgo.MessageCreate, pubkeys map[string]string) [][]string {
+	var tags [][]string
+	seen := make(map[string]bool)
+	for _, user := range m.Mentions {
+		pubkey, ok := pubkeys[user.ID]
+		if !ok || seen[pubkey] {
+			continue
+		}
+		seen[pubkey] = true
+		tags = append(tags, []string{"p", pubkey})
+	}
+	return tags
+}
+
+// authorTags attributes the note to the Discord author: a NIP-48 proxy tag linking the original
+// message and an author tag with the author's display name and avatar URL
+func authorTags(m *discordgo.MessageCreate) [][]string {
+	var tags [][]string
+	if m.GuildID != "" {
+		messageURL := fmt.Sprintf("https://discord.com/channels/%s/%s/%s", m.GuildID, m.ChannelID, m.ID)
+		tags = append(tags, []string{"proxy", messageURL, "web"})
+	}
+
+	return append(tags, []string{"author", userDisplayName(m.Author), m.Author.AvatarURL("")})
+}
+
+// hasRole reports whether the author of the message has the given guild role
+func hasRole(s discordSession, m *discordgo.MessageCreate, roleID string) bool {
+	member := m.Member
+	if member == nil {
+		var err error
+		member, err = s.cache().Member(m.GuildID, m.Author.ID)
+		if err != nil {
+			member, err = s.GuildMember(m.GuildID, m.Author.ID)
+			if err != nil {
+				log.Printf("Error looking up roles of %s: %v", m.Author.Username, err)
+				return false
+			}
+		}
+	}
+
+	for _, role := range member.Roles {
+		if role == roleID {
+			return true
+		}
+	}
+	return false
+}
+
+// forumTags maps the forum tags applied to a forum post thread to Nostr t tags
+func forumTags(s discordSession, thread *discordgo.Channel) [][]string {
+	if len(thread.AppliedTags) == 0 {
+		return nil
+	}
+
+	forum := lookupChannel(s, thread.ParentID)
+	if forum == nil || forum.Type != discordgo.ChannelTypeGuildForum {
+		return nil
+	}
+
+	var tags [][]string
+	for _, applied := range thread.AppliedTags {
+		for _, available := range forum.AvailableTags {
+			if available.ID != applied {
+				continue
+			}
+			hashtag := strings.ToLower(strings.Join(strings.Fields(available.Name), ""))
+			if hashtag != "" {
+				tags = append(tags, []string{"t", hashtag})
+			}
+		}
+	}
+	return tags
+}
+
+// watchedChannel returns the config of a watched channel, or of the parent channel for threads. It
+// returns nil for channels that aren't bridged.
+func (b *Bridge) watchedChannel(s discordSession, channelID string) *utils.ChannelConfig {
+	if channelConfig := b.config.Channel(channelID); channelConfig != nil {
+		return channelConfig
+	}
+	if channel := lookupChannel(s, channelID); channel != nil && channel.IsThread() {
+		return b.config.Channel(channel.ParentID)
+	}
+	return nil
+}
+
+// lookupChannel returns the channel from the session state, falling back to the Discord API
+func lookupChannel(s discordSession, channelID string) *discordgo.Channel {
+	if channel, err := s.cache().Channel(channelID); err == nil {
+		return channel
+	}
+
+	channel, err := s.Channel(channelID)
+	if err != nil {
+		log.Printf("Error looking up channel %s: %v", channelID, err)
+		return nil
+	}
+	return channel
+}
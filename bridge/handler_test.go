@@ -0,0 +1,297 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	testBotID     = "100"
+	testChannelID = "200"
+)
+
+// fakeSession is a discordSession without a connection. Every API call fails, so handlers only
+// see what is in its state.
+type fakeSession struct {
+	state *discordgo.State
+}
+
+func newFakeSession() *fakeSession {
+	state := discordgo.NewState()
+	state.User = &discordgo.User{ID: testBotID, Username: "bridge"}
+	return &fakeSession{state: state}
+}
+
+var errNoConnection = errors.New("fake session has no connection")
+
+func (s *fakeSession) Channel(string, ...discordgo.RequestOption) (*discordgo.Channel, error) {
+	return nil, errNoConnection
+}
+
+func (s *fakeSession) ChannelMessagesPinned(string, ...discordgo.RequestOption) ([]*discordgo.Message, error) {
+	return nil, errNoConnection
+}
+
+func (s *fakeSession) ChannelMessageSendComplex(string, *discordgo.MessageSend, ...discordgo.RequestOption) (*discordgo.Message, error) {
+	return nil, errNoConnection
+}
+
+func (s *fakeSession) GuildMember(string, string, ...discordgo.RequestOption) (*discordgo.Member, error) {
+	return nil, errNoConnection
+}
+
+func (s *fakeSession) GuildRoles(string, ...discordgo.RequestOption) ([]*discordgo.Role, error) {
+	return nil, errNoConnection
+}
+
+func (s *fakeSession) MessageReactionAdd(string, string, string, ...discordgo.RequestOption) error {
+	return errNoConnection
+}
+
+func (s *fakeSession) User(string, ...discordgo.RequestOption) (*discordgo.User, error) {
+	return nil, errNoConnection
+}
+
+func (s *fakeSession) cache() *discordgo.State {
+	return s.state
+}
+
+// newTestBridge returns a bridge watching testChannelID that collects bridged messages in its
+// digest instead of publishing them
+func newTestBridge() *Bridge {
+	config := &utils.Config{}
+	config.Discord.Channels = []utils.ChannelConfig{{ID: testChannelID}}
+	b := &Bridge{config: config, posted: newPostedMessages(), reports: newReportLog()}
+	b.digest = &digest{bridge: b}
+	return b
+}
+
+// testRelay is a relay that accepts every event and records it
+type testRelay struct {
+	url string
+
+	mu     sync.Mutex
+	events []nostr.NostrEvent
+}
+
+func startTestRelay(t *testing.T) *testRelay {
+	t.Helper()
+	relay := &testRelay{}
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame []json.RawMessage
+			var event nostr.NostrEvent
+			if json.Unmarshal(message, &frame) != nil || len(frame) < 2 || json.Unmarshal(frame[1], &event) != nil {
+				continue
+			}
+			relay.mu.Lock()
+			relay.events = append(relay.events, event)
+			relay.mu.Unlock()
+			if err := ws.WriteJSON([]any{"OK", event.ID, true, ""}); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		nostr.CloseRelays()
+		server.Close()
+	})
+	relay.url = "ws" + strings.TrimPrefix(server.URL, "http")
+	return relay
+}
+
+// published returns the events the relay received so far
+func (r *testRelay) published() []nostr.NostrEvent {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.events)
+}
+
+// newPublishingBridge returns a bridge like newTestBridge that publishes notes to the relay
+func newPublishingBridge(t *testing.T, relay *testRelay) *Bridge {
+	t.Helper()
+	const privKey = "0000000000000000000000000000000000000000000000000000000000000007"
+	signer, err := nostr.NewKeySigner(privKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := newTestBridge()
+	b.digest = nil
+	b.signer = signer
+	b.config.Nostr.Pubkey = signer.PublicKey()
+	b.config.Nostr.Relays = []string{relay.url}
+	if b.events, err = loadEventMap(""); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// eTags returns the e tags of the event
+func eTags(event nostr.NostrEvent) [][]string {
+	var tags [][]string
+	for _, tag := range event.Tags {
+		if tag[0] == "e" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func testMessage(id, authorID string) *discordgo.MessageCreate {
+	return &discordgo.MessageCreate{Message: &discordgo.Message{
+		ID:        id,
+		ChannelID: testChannelID,
+		Content:   "hello nostr",
+		Author:    &discordgo.User{ID: authorID, Username: "user" + authorID},
+	}}
+}
+
+func TestBridgeMessageIgnoresOwnMessages(t *testing.T) {
+	b := newTestBridge()
+	s := newFakeSession()
+	b.posted.add("2")
+
+	b.bridgeMessage(context.Background(), s, testMessage("1", testBotID), nil)
+	b.bridgeMessage(context.Background(), s, testMessage("2", "300"), nil)
+	if n := len(b.digest.entries); n != 0 {
+		t.Fatalf("bridgeMessage() bridged %d of the bridge's own messages, want none", n)
+	}
+
+	// Other messages in the channel still go through
+	b.bridgeMessage(context.Background(), s, testMessage("3", "300"), nil)
+	if n := len(b.digest.entries); n != 1 {
+		t.Errorf("bridgeMessage() bridged %d messages of other users, want 1", n)
+	}
+}
+
+// testReply returns a message of user 300 replying to the message with the given ID
+func testReply(id, parentID string) *discordgo.MessageCreate {
+	m := testMessage(id, "300")
+	m.Type = discordgo.MessageTypeReply
+	m.MessageReference = &discordgo.MessageReference{MessageID: parentID, ChannelID: testChannelID}
+	m.ReferencedMessage = testMessage(parentID, "300").Message
+	return m
+}
+
+func TestReplyTags(t *testing.T) {
+	const relay = "wss://relay.example"
+	direct := nostr.ReplyTags("root", "root", relay)
+	if want := [][]string{{"e", "root", relay, "root"}}; !slices.EqualFunc(direct, want, slices.Equal) {
+		t.Errorf("ReplyTags() of a direct reply = %v, want %v", direct, want)
+	}
+
+	nested := nostr.ReplyTags("root", "middle", relay)
+	if want := [][]string{{"e", "root", relay, "root"}, {"e", "middle", relay, "reply"}}; !slices.EqualFunc(nested, want, slices.Equal) {
+		t.Errorf("ReplyTags() of a reply to a reply = %v, want %v", nested, want)
+	}
+}
+
+func TestBridgeMessageReplyChain(t *testing.T) {
+	relay := startTestRelay(t)
+	b := newPublishingBridge(t, relay)
+	s := newFakeSession()
+	ctx := context.Background()
+
+	b.bridgeMessage(ctx, s, testMessage("1", "300"), nil)
+	b.bridgeMessage(ctx, s, testReply("2", "1"), nil)
+	b.bridgeMessage(ctx, s, testReply("3", "2"), nil)
+
+	events := relay.published()
+	if len(events) != 3 {
+		t.Fatalf("bridgeMessage() published %d notes, want 3", len(events))
+	}
+	root, middle, leaf := events[0], events[1], events[2]
+	if tags := eTags(root); len(tags) != 0 {
+		t.Errorf("note of the first message has e tags %v, want none", tags)
+	}
+	if want := [][]string{{"e", root.ID, relay.url, "root"}}; !slices.EqualFunc(eTags(middle), want, slices.Equal) {
+		t.Errorf("note of the direct reply has e tags %v, want %v", eTags(middle), want)
+	}
+	want := [][]string{{"e", root.ID, relay.url, "root"}, {"e", middle.ID, relay.url, "reply"}}
+	if !slices.EqualFunc(eTags(leaf), want, slices.Equal) {
+		t.Errorf("note of the reply to the reply has e tags %v, want %v", eTags(leaf), want)
+	}
+
+	// The event map keeps the thread root of every message
+	if bridged, _ := b.events.Get("3"); bridged.EventID != leaf.ID || bridged.threadRoot() != root.ID {
+		t.Errorf("event map has %+v for the reply to the reply, want event %s in thread %s", bridged, leaf.ID, root.ID)
+	}
+}
+
+func TestBridgeMessageReplyToDeletedMessage(t *testing.T) {
+	for _, mark := range []bool{false, true} {
+		relay := startTestRelay(t)
+		b := newPublishingBridge(t, relay)
+		b.config.Content.MarkDeletedReplies = mark
+		s := newFakeSession()
+		ctx := context.Background()
+
+		// The referenced message was bridged before it was deleted, so its note is still known
+		b.bridgeMessage(ctx, s, testMessage("1", "300"), nil)
+		reply := testReply("2", "1")
+		reply.ReferencedMessage = nil
+		b.bridgeMessage(ctx, s, reply, nil)
+
+		events := relay.published()
+		if len(events) != 2 {
+			t.Fatalf("bridgeMessage() published %d notes, want 2", len(events))
+		}
+		note := events[1]
+		for _, tag := range note.Tags {
+			if tag[0] == "e" || tag[0] == "p" {
+				t.Errorf("note of the reply to a deleted message has tag %v, want no reply tags", tag)
+			}
+		}
+		want := "hello nostr"
+		if mark {
+			want = "(reply to a deleted message)\n" + want
+		}
+		if note.Content != want {
+			t.Errorf("note content with mark_deleted_replies %t = %q, want %q", mark, note.Content, want)
+		}
+	}
+}
+
+func TestMessageCreateHandlerDropsMessagesWhilePaused(t *testing.T) {
+	b := newTestBridge()
+	s := newFakeSession()
+	b.scheduler = &scheduler{bridge: b, delay: time.Hour, wake: make(chan struct{}, 1)}
+	ctx := context.Background()
+
+	// A message received while paused isn't scheduled, so it isn't bridged after resuming either
+	b.Pause()
+	b.messageCreateHandler(ctx, s, testMessage("1", "300"))
+	b.Resume()
+	if n := len(b.scheduler.queue); n != 0 {
+		t.Fatalf("messageCreateHandler() scheduled %d messages received while paused, want none", n)
+	}
+
+	b.messageCreateHandler(ctx, s, testMessage("2", "300"))
+	b.messageCreateHandler(ctx, s, testMessage("3", testBotID))
+	if n := len(b.scheduler.queue); n != 1 {
+		t.Errorf("messageCreateHandler() scheduled %d messages after resuming, want 1", n)
+	}
+}
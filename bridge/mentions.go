@@ -0,0 +1,162 @@
+package bridge
+
+import (
+	"log"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// mentionRe splits a Discord mention into its kind (@, @! or @& for users and roles, # for
+// channels) and ID
+var mentionRe = regexp.MustCompile(`^<(@!?|@&|#)([0-9]+)>$`)
+
+// mentionName is a cached display name with the time it must be looked up again. The name is
+// empty when the lookup failed, so unknown IDs don't hit the Discord API on every message.
+type mentionName struct {
+	name    string
+	expires time.Time
+}
+
+// mentionNames resolves user, role and channel mentions to their names, caching them for ttl
+type mentionNames struct {
+	ttl   time.Duration
+	mu    sync.Mutex
+	names map[string]mentionName
+}
+
+// newMentionNames creates an empty cache keeping names for ttl
+func newMentionNames(ttl time.Duration) *mentionNames {
+	return &mentionNames{ttl: ttl, names: make(map[string]mentionName)}
+}
+
+// mentionNameFunc returns the function resolving mentions in the message for nostr.ContentOptions,
+// or nil when mentions are stripped
+func (b *Bridge) mentionNameFunc(s discordSession, m *discordgo.MessageCreate) func(string) string {
+	if b.mentionNames == nil {
+		return nil
+	}
+	return func(mention string) string {
+		return b.mentionNames.replace(s, m, mention)
+	}
+}
+
+// replace returns the text replacing the mention in the message, e.g. "@name" or "#channel", or ""
+// when the name is unknown
+func (c *mentionNames) replace(s discordSession, m *discordgo.MessageCreate, mention string) string {
+	match := mentionRe.FindStringSubmatch(mention)
+	if match == nil {
+		return ""
+	}
+
+	// The prefix doubles as the cache key kind, so @ and @! of the same user share an entry
+	kind, id := match[1], match[2]
+	if kind == "@!" {
+		kind = "@"
+	}
+	name, ok := c.get(kind + id)
+	if !ok {
+		switch kind {
+		case "@":
+			name = c.userName(s, m, id)
+		case "@&":
+			name = c.roleName(s, m.GuildID, id)
+		case "#":
+			name = c.channelName(s, id)
+		}
+		c.set(kind+id, name)
+	}
+
+	if name == "" {
+		return ""
+	}
+	if kind == "#" {
+		return "#" + name
+	}
+	return "@" + name
+}
+
+// userName looks the user up in the guild state, the message's mentions and finally the Discord API,
+// preferring the guild nickname over the display name and username
+func (c *mentionNames) userName(s discordSession, m *discordgo.MessageCreate, id string) string {
+	if member, err := s.cache().Member(m.GuildID, id); err == nil {
+		if member.Nick != "" {
+			return member.Nick
+		}
+		if member.User != nil {
+			return userDisplayName(member.User)
+		}
+	}
+	for _, user := range m.Mentions {
+		if user.ID == id {
+			return userDisplayName(user)
+		}
+	}
+
+	user, err := s.User(id)
+	if err != nil {
+		log.Printf("Error looking up mentioned user %s: %v", id, err)
+		return ""
+	}
+	return userDisplayName(user)
+}
+
+// roleName looks the role up in the guild state, falling back to fetching and caching all of the
+// guild's roles at once
+func (c *mentionNames) roleName(s discordSession, guildID, id string) string {
+	if role, err := s.cache().Role(guildID, id); err == nil {
+		return role.Name
+	}
+
+	roles, err := s.GuildRoles(guildID)
+	if err != nil {
+		log.Printf("Error looking up roles of guild %s: %v", guildID, err)
+		return ""
+	}
+	name := ""
+	for _, role := range roles {
+		c.set("@&"+role.ID, role.Name)
+		if role.ID == id {
+			name = role.Name
+		}
+	}
+	return name
+}
+
+// channelName looks the channel up in the session state, falling back to the Discord API
+func (c *mentionNames) channelName(s discordSession, id string) string {
+	if channel := lookupChannel(s, id); channel != nil {
+		return channel.Name
+	}
+	return ""
+}
+
+// get returns the cached name for the key if it hasn't expired
+func (c *mentionNames) get(key string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.names[key]
+	if !ok || time.Now().After(entry.expires) {
+		delete(c.names, key)
+		return "", false
+	}
+	return entry.name, true
+}
+
+// set caches the name for the key
+func (c *mentionNames) set(key, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names[key] = mentionName{name: name, expires: time.Now().Add(c.ttl)}
+}
+
+// userDisplayName returns the user's display name, or the username if they have none
+func userDisplayName(user *discordgo.User) string {
+	if user.GlobalName != "" {
+		return user.GlobalName
+	}
+	return user.Username
+}
@@ -0,0 +1,151 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// approvalQueue stages incoming messages until a moderator approves them with the configured reaction.
+// Messages that aren't approved within the timeout are dropped. Staged messages live in memory only,
+// so they are lost on shutdown.
+type approvalQueue struct {
+	bridge  *Bridge
+	emoji   string
+	roles   []string
+	users   []string
+	timeout time.Duration
+
+	mu     sync.Mutex
+	staged map[string]stagedMessage
+}
+
+// stagedMessage is a received message or an edit of a bridged message waiting for approval
+type stagedMessage struct {
+	ctx      context.Context
+	session  discordSession
+	message  *discordgo.MessageCreate
+	edit     *discordgo.MessageUpdate // Set instead of message for edits
+	stagedAt time.Time
+}
+
+// startApprovals creates the approval queue and drops expired messages until ctx is done
+func startApprovals(ctx context.Context, b *Bridge) *approvalQueue {
+	aq := &approvalQueue{
+		bridge:  b,
+		emoji:   b.config.Moderation.Emoji,
+		roles:   b.config.Moderation.Roles,
+		users:   b.config.Moderation.Users,
+		timeout: b.config.Moderation.Timeout,
+		staged:  make(map[string]stagedMessage),
+	}
+	go aq.expire(ctx)
+	log.Printf("Moderation enabled, messages are bridged once approved with %s", aq.emoji)
+	return aq
+}
+
+// stage holds the message until a moderator approves it. messageCreateHandler only stages messages
+// that would be bridged, so moderators aren't asked about messages in other channels.
+func (aq *approvalQueue) stage(ctx context.Context, s discordSession, m *discordgo.MessageCreate) {
+
+	aq.mu.Lock()
+	aq.staged[m.ID] = stagedMessage{ctx: ctx, session: s, message: m, stagedAt: time.Now()}
+	pending := len(aq.staged)
+	aq.mu.Unlock()
+	log.Printf("Message %s from %s staged for approval, %d messages pending", m.ID, m.Author.Username, pending)
+}
+
+// stageEdit holds the edit of a bridged message until a moderator approves it again. Editing a
+// message that is still waiting for approval replaces the staged text, so the approval bridges what
+// the moderator sees.
+func (aq *approvalQueue) stageEdit(ctx context.Context, s discordSession, m *discordgo.MessageUpdate) {
+	aq.mu.Lock()
+	defer aq.mu.Unlock()
+
+	if staged, ok := aq.staged[m.ID]; ok && staged.edit == nil {
+		staged.message = &discordgo.MessageCreate{Message: m.Message}
+		aq.staged[m.ID] = staged
+		log.Printf("Staged message %s was edited, its approval bridges the edited text", m.ID)
+		return
+	}
+	if bridged, ok := aq.bridge.events.Get(m.ID); !ok || bridged.EventID == "" {
+		return
+	}
+
+	aq.staged[m.ID] = stagedMessage{ctx: ctx, session: s, edit: m, stagedAt: time.Now()}
+	log.Printf("Edit of message %s staged for approval, react with %s again to bridge it", m.ID, aq.emoji)
+}
+
+// reactionAddHandler releases a staged message when a moderator reacts with the approval emoji
+func (aq *approvalQueue) reactionAddHandler(s discordSession, r *discordgo.MessageReactionAdd) {
+	if r.Emoji.APIName() != aq.emoji || !aq.isModerator(s, r) {
+		return
+	}
+
+	aq.mu.Lock()
+	staged, ok := aq.staged[r.MessageID]
+	delete(aq.staged, r.MessageID)
+	aq.mu.Unlock()
+	if !ok {
+		return
+	}
+
+	if staged.edit != nil {
+		log.Printf("Edit of message %s approved by %s", r.MessageID, r.UserID)
+		aq.bridge.bridgeEdit(staged.ctx, staged.session, staged.edit)
+		return
+	}
+	log.Printf("Message %s approved by %s", r.MessageID, r.UserID)
+	aq.bridge.releaseMessage(staged.ctx, staged.session, staged.message)
+}
+
+// isModerator reports whether the user who reacted may approve messages
+func (aq *approvalQueue) isModerator(s discordSession, r *discordgo.MessageReactionAdd) bool {
+	if slices.Contains(aq.users, r.UserID) {
+		return true
+	}
+	if len(aq.roles) == 0 || r.GuildID == "" {
+		return false
+	}
+
+	member := r.Member
+	if member == nil {
+		var err error
+		member, err = s.cache().Member(r.GuildID, r.UserID)
+		if err != nil {
+			member, err = s.GuildMember(r.GuildID, r.UserID)
+			if err != nil {
+				log.Printf("Error looking up roles of %s: %v", r.UserID, err)
+				return false
+			}
+		}
+	}
+	return slices.ContainsFunc(member.Roles, func(role string) bool { return slices.Contains(aq.roles, role) })
+}
+
+// expire drops messages that were not approved within the timeout
+func (aq *approvalQueue) expire(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+
+		aq.mu.Lock()
+		for id, staged := range aq.staged {
+			if time.Since(staged.stagedAt) >= aq.timeout {
+				delete(aq.staged, id)
+				log.Printf("Dropping message %s that was not approved within %s", id, aq.timeout)
+			}
+		}
+		aq.mu.Unlock()
+	}
+}
@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+func TestStageOnlyBridgedMessages(t *testing.T) {
+	b := newTestBridge()
+	s := newFakeSession()
+	aq := &approvalQueue{bridge: b, staged: make(map[string]stagedMessage)}
+	b.approvals = aq
+	ctx := context.Background()
+
+	// A thread of the watched channel
+	if err := s.state.GuildAdd(&discordgo.Guild{ID: "10"}); err != nil {
+		t.Fatal(err)
+	}
+	thread := &discordgo.Channel{ID: "201", GuildID: "10", ParentID: testChannelID, Type: discordgo.ChannelTypeGuildPublicThread}
+	if err := s.state.ChannelAdd(thread); err != nil {
+		t.Fatal(err)
+	}
+
+	own := testMessage("1", testBotID)
+	posted := testMessage("2", "300")
+	b.posted.add(posted.ID)
+	unwatched := testMessage("3", "300")
+	unwatched.ChannelID = "999"
+	watched := testMessage("4", "300")
+	inThread := testMessage("5", "300")
+	inThread.ChannelID = thread.ID
+	for _, m := range []*discordgo.MessageCreate{own, posted, unwatched, watched, inThread} {
+		b.messageCreateHandler(ctx, s, m)
+	}
+	for _, id := range []string{own.ID, posted.ID, unwatched.ID} {
+		if _, ok := aq.staged[id]; ok {
+			t.Errorf("messageCreateHandler() staged message %s, which isn't bridged", id)
+		}
+	}
+	for _, id := range []string{watched.ID, inThread.ID} {
+		if _, ok := aq.staged[id]; !ok {
+			t.Errorf("messageCreateHandler() didn't stage message %s of a watched channel", id)
+		}
+	}
+
+	// Messages received while paused are dropped, not staged for later
+	b.Pause()
+	b.messageCreateHandler(ctx, s, testMessage("6", "300"))
+	if _, ok := aq.staged["6"]; ok {
+		t.Error("messageCreateHandler() staged a message received while paused")
+	}
+}
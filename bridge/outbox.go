@@ -0,0 +1,95 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// outboxSize is how many Discord posts may wait before queueing one blocks the relay subscription
+const outboxSize = 256
+
+// minRateLimitWait is the wait after Discord rate limited a post without saying when to retry
+const minRateLimitWait = time.Second
+
+// discordPost is a message waiting to be posted to a Discord channel
+type discordPost struct {
+	channelID   string
+	message     *discordgo.MessageSend
+	description string // What is posted, for logging, e.g. "Nostr reply <id>"
+}
+
+// discordOutbox posts messages to Discord one at a time in the order they were queued. Posting
+// outside the relay read loop keeps subscriptions reading while a post waits out a rate limit.
+type discordOutbox struct {
+	session discordSession
+	posted  *postedMessages
+	queue   chan discordPost
+	done    <-chan struct{}
+}
+
+// startDiscordOutbox creates the outbox and posts queued messages until ctx is done
+func startDiscordOutbox(ctx context.Context, session discordSession, posted *postedMessages) *discordOutbox {
+	o := &discordOutbox{session: session, posted: posted, queue: make(chan discordPost, outboxSize), done: ctx.Done()}
+	go o.run(ctx)
+	return o
+}
+
+// post queues the message for the channel, dropping it once the outbox has stopped. The text comes
+// from Nostr, so it is posted without pinging anyone it mentions.
+func (o *discordOutbox) post(channelID string, message *discordgo.MessageSend, description string) {
+	message.AllowedMentions = &discordgo.MessageAllowedMentions{}
+	select {
+	case o.queue <- discordPost{channelID: channelID, message: message, description: description}:
+	case <-o.done:
+	}
+}
+
+// run posts the queued messages until ctx is done
+func (o *discordOutbox) run(ctx context.Context) {
+	for {
+		select {
+		case p := <-o.queue:
+			o.send(ctx, p)
+		case <-ctx.Done():
+			if pending := len(o.queue); pending > 0 {
+				log.Printf("Dropping %d messages that were not posted to Discord yet", pending)
+			}
+			return
+		}
+	}
+}
+
+// send posts the message, waiting out rate limits until Discord accepts it or ctx is done. The
+// session retries rate limited requests itself unless ShouldRetryOnRateLimit is off, so this covers
+// sessions passed in by an embedding program.
+func (o *discordOutbox) send(ctx context.Context, p discordPost) {
+	for {
+		posted, err := o.session.ChannelMessageSendComplex(p.channelID, p.message)
+		if err == nil {
+			o.posted.add(posted.ID)
+			log.Printf("%s posted to Discord", p.description)
+			return
+		}
+
+		var rateLimited *discordgo.RateLimitError
+		if !errors.As(err, &rateLimited) {
+			log.Printf("Error posting %s to Discord: %v", p.description, err)
+			return
+		}
+		wait := rateLimited.RetryAfter
+		if wait <= 0 {
+			wait = minRateLimitWait
+		}
+		log.Printf("Discord rate limited posting %s, retrying in %s", p.description, wait)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			log.Printf("Gave up posting %s to Discord: %v", p.description, ctx.Err())
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// postingSession is a fakeSession that accepts posts, recording them as messages of the bot
+type postingSession struct {
+	*fakeSession
+	sent []*discordgo.Message
+}
+
+func (s *postingSession) ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, _ ...discordgo.RequestOption) (*discordgo.Message, error) {
+	message := &discordgo.Message{
+		ID:        strconv.Itoa(1000 + len(s.sent)),
+		ChannelID: channelID,
+		Content:   data.Content,
+		Author:    s.state.User,
+	}
+	s.sent = append(s.sent, message)
+	return message, nil
+}
+
+func TestOutboxPostsAreNotBridgedBack(t *testing.T) {
+	relay := startTestRelay(t)
+	b := newPublishingBridge(t, relay)
+	s := &postingSession{fakeSession: newFakeSession()}
+	o := &discordOutbox{session: s, posted: b.posted}
+	ctx := context.Background()
+
+	o.send(ctx, discordPost{
+		channelID:   testChannelID,
+		message:     &discordgo.MessageSend{Content: "hello discord"},
+		description: "Nostr note",
+	})
+	if len(s.sent) != 1 {
+		t.Fatalf("send() posted %d messages, want 1", len(s.sent))
+	}
+
+	// Discord echoes the post back as a message of the bot
+	echo := &discordgo.MessageCreate{Message: s.sent[0]}
+	b.messageCreateHandler(ctx, s, echo)
+
+	// The posted message ID identifies the post even when it shows up under another author
+	relayed := *s.sent[0]
+	relayed.Author = &discordgo.User{ID: "300", Username: "user300"}
+	b.messageCreateHandler(ctx, s, &discordgo.MessageCreate{Message: &relayed})
+
+	if events := relay.published(); len(events) != 0 {
+		t.Errorf("messageCreateHandler() published %d notes of messages posted by the outbox, want none", len(events))
+	}
+
+	// Messages of users in the channel are still bridged
+	b.messageCreateHandler(ctx, s, testMessage("1", "300"))
+	if events := relay.published(); len(events) != 1 {
+		t.Errorf("messageCreateHandler() published %d notes of a user's message, want 1", len(events))
+	}
+}
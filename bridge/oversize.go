@@ -0,0 +1,97 @@
+package bridge
+
+import (
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+)
+
+// minSplitLength is the shortest part oversized content is split into before the message is skipped
+const minSplitLength = 100
+
+// truncationMarker ends content that was cut to fit the relays
+const truncationMarker = "…"
+
+// fitContent returns the parts content is published as, split at the configured split length.
+// Parts too large for the relays are split further, truncated or skipped as configured; nil
+// means the message should be skipped.
+func (b *Bridge) fitContent(messageID, content string, tags [][]string) []string {
+	config := b.config
+	fits := func(parts []string) bool { return b.partsFit(parts, tags) }
+
+	limit := len([]rune(content))
+	if config.Content.SplitLength > 0 {
+		limit = min(limit, config.Content.SplitLength)
+	}
+	parts := nostr.SplitContent(content, max(limit, 1))
+	if fits(parts) {
+		return parts
+	}
+
+	switch config.Content.Oversize {
+	case utils.OversizeSplit:
+		if parts := b.splitToFit(content, tags, limit); parts != nil {
+			log.Printf("Message %s is too large for the relays, splitting it into %d notes", messageID, len(parts))
+			return parts
+		}
+	case utils.OversizeTruncate:
+		// Only the parts that don't fit are cut, the rest of the chain is kept as it is
+		truncated, ok := 0, true
+		for i, part := range parts {
+			if fits([]string{part}) {
+				continue
+			}
+			length := truncatedLength(part, func(prefix string) bool { return fits([]string{prefix + truncationMarker}) })
+			if length == 0 {
+				ok = false
+				break
+			}
+			parts[i] = string([]rune(part)[:length]) + truncationMarker
+			truncated++
+		}
+		if ok {
+			log.Printf("Message %s is too large for the relays, truncating %d of its %d notes", messageID, truncated, len(parts))
+			return parts
+		}
+	}
+
+	log.Printf("Skipping message %s, its note is too large for the relays", messageID)
+	return nil
+}
+
+// splitToFit shrinks the parts content is split into below limit characters until each one fits the
+// relays. It returns nil when even parts of minSplitLength characters are too large.
+func (b *Bridge) splitToFit(content string, tags [][]string, limit int) []string {
+	for limit = limit * 3 / 4; limit >= minSplitLength; limit = limit * 3 / 4 {
+		if parts := nostr.SplitContent(content, limit); b.partsFit(parts, tags) {
+			return parts
+		}
+	}
+	return nil
+}
+
+// partsFit reports whether every part fits the relays as a note with the tags
+func (b *Bridge) partsFit(parts []string, tags [][]string) bool {
+	relays := b.config.RelaysForKind(1)
+	for _, part := range parts {
+		if !nostr.FitsRelays(1, part, b.config.Nostr.Pubkey, tags, relays) {
+			return false
+		}
+	}
+	return true
+}
+
+// truncatedLength returns the length in characters of the longest prefix of part that fits
+func truncatedLength(part string, fits func(prefix string) bool) int {
+	runes := []rune(part)
+	low, high := 0, len(runes)
+	for low < high {
+		mid := (low + high + 1) / 2
+		if fits(string(runes[:mid])) {
+			low = mid
+		} else {
+			high = mid - 1
+		}
+	}
+	return low
+}
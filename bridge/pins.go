@@ -0,0 +1,54 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"ndmBridge/nostr"
+	"slices"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// channelPinsUpdateHandler republishes the NIP-51 bookmark set of a watched channel's pinned messages
+// whenever its pins change. Pinned messages that were never bridged are left out.
+func (b *Bridge) channelPinsUpdateHandler(ctx context.Context, s discordSession, p *discordgo.ChannelPinsUpdate) {
+	if b.config.Channel(p.ChannelID) == nil {
+		return
+	}
+	if b.Paused() {
+		log.Printf("Bridging is paused, not updating pins of channel %s", p.ChannelID)
+		return
+	}
+
+	pinned, err := s.ChannelMessagesPinned(p.ChannelID)
+	if err != nil {
+		log.Printf("Error fetching pinned messages of channel %s: %v", p.ChannelID, err)
+		return
+	}
+	// Discord lists the newest pin first, while NIP-51 lists append new items at the end
+	slices.Reverse(pinned)
+
+	relayHint := b.config.RelaysForKind(1)[0]
+	tags := [][]string{{"d", "discord-pins-" + p.ChannelID}}
+	if channel := lookupChannel(s, p.ChannelID); channel != nil && channel.Name != "" {
+		tags = append(tags, []string{"title", "Pinned in #" + channel.Name})
+	}
+	bookmarked := 0
+	for _, msg := range pinned {
+		if bridged, ok := b.events.Get(msg.ID); ok && bridged.EventID != "" {
+			tags = append(tags, []string{"e", bridged.EventID, relayHint})
+			bookmarked++
+		}
+	}
+
+	event, err := nostr.CreateEvent(nostr.BookmarkSetKind, "", b.config.Nostr.Pubkey, tags)
+	if err != nil {
+		log.Printf("Error creating pin list event: %v", err)
+		return
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, event, b.signer, b.config.RelaysForKind(event.Kind)); err != nil {
+		log.Printf("Error sending pin list event: %v", err)
+		return
+	}
+	log.Printf("Pin list of channel %s published as %s with %d of %d pinned messages", p.ChannelID, event.ID, bookmarked, len(pinned))
+}
@@ -0,0 +1,43 @@
+package bridge
+
+import "sync"
+
+// maxPostedMessages is how many message IDs postedMessages remembers
+const maxPostedMessages = 1000
+
+// postedMessages remembers the Discord messages the bridge posted itself, such as mirrored Nostr
+// replies, so they are never bridged back to Nostr in a loop. This also covers messages that don't
+// arrive as the bot's own, for example when the bridge is embedded with another session.
+type postedMessages struct {
+	mu    sync.Mutex
+	ids   map[string]bool
+	order []string
+}
+
+// newPostedMessages creates an empty set of posted messages
+func newPostedMessages() *postedMessages {
+	return &postedMessages{ids: make(map[string]bool)}
+}
+
+// add records the message, forgetting the oldest one once the set is full
+func (p *postedMessages) add(id string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.ids[id] {
+		return
+	}
+	if len(p.order) >= maxPostedMessages {
+		delete(p.ids, p.order[0])
+		p.order = p.order[1:]
+	}
+	p.ids[id] = true
+	p.order = append(p.order, id)
+}
+
+// has reports whether the bridge posted the message
+func (p *postedMessages) has(id string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.ids[id]
+}
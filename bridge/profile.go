@@ -0,0 +1,118 @@
+package bridge
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"time"
+)
+
+// profileFetchTimeout bounds fetching the current profile from each relay
+const profileFetchTimeout = 10 * time.Second
+
+// profileSync keeps the about field of the bridge's Nostr profile in line with a Discord channel topic
+type profileSync struct {
+	bridge    *Bridge
+	channelID string
+	lastTopic *string // Topic of the last sync that left the profile up to date
+}
+
+// startProfileSync syncs the topic right away and then every interval until ctx is done
+func startProfileSync(ctx context.Context, b *Bridge) {
+	ps := &profileSync{bridge: b, channelID: b.config.Profile.TopicChannel}
+	go func() {
+		ticker := time.NewTicker(b.config.Profile.Interval)
+		defer ticker.Stop()
+		for {
+			if err := ps.sync(ctx); err != nil {
+				log.Printf("Error syncing channel topic to profile: %v", err)
+			}
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	log.Printf("Syncing the topic of channel %s to the profile every %s", ps.channelID, b.config.Profile.Interval)
+}
+
+// sync publishes a kind-0 metadata update when the channel topic differs from the profile's about
+// field. The other profile fields are taken from the newest metadata found on the relays.
+func (ps *profileSync) sync(ctx context.Context) error {
+	channel := lookupChannel(ps.bridge.discord(), ps.channelID)
+	if channel == nil {
+		return fmt.Errorf("cannot look up channel %s", ps.channelID)
+	}
+	topic := channel.Topic
+	if ps.lastTopic != nil && *ps.lastTopic == topic {
+		return nil
+	}
+
+	config := ps.bridge.config
+	relays := config.RelaysForKind(nostr.MetadataKind)
+	metadata, err := fetchMetadata(ctx, relays, config.Nostr.Pubkey)
+	if err != nil {
+		return err
+	}
+
+	if about, _ := metadata["about"].(string); about == topic {
+		ps.lastTopic = &topic
+		return nil
+	}
+	metadata["about"] = topic
+	content, err := json.Marshal(metadata)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile: %w", err)
+	}
+
+	event, err := nostr.CreateEvent(nostr.MetadataKind, string(content), config.Nostr.Pubkey, nil)
+	if err != nil {
+		return err
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, event, ps.bridge.signer, relays); err != nil {
+		return fmt.Errorf("failed to publish profile update: %w", err)
+	}
+	ps.lastTopic = &topic
+	log.Printf("Profile about updated to the topic of channel %s", ps.channelID)
+	return nil
+}
+
+// fetchMetadata returns the fields of the newest valid kind-0 profile of pubkey on the relays, or an
+// empty profile when there is none. It fails when no relay could be asked, so a profile that merely
+// couldn't be fetched is never overwritten.
+func fetchMetadata(ctx context.Context, relays []string, pubkey string) (map[string]any, error) {
+	var newest *nostr.NostrEvent
+	var errs []error
+	for _, relayURL := range relays {
+		fetchCtx, cancel := context.WithTimeout(ctx, profileFetchTimeout)
+		events, err := nostr.FetchEvents(fetchCtx, relayURL, nostr.Filter{Kinds: []int{nostr.MetadataKind}, Authors: []string{pubkey}, Limit: 1})
+		cancel()
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", relayURL, err))
+			continue
+		}
+		for _, event := range events {
+			if event.Pubkey != pubkey {
+				continue
+			}
+			if newest == nil || event.CreatedAt > newest.CreatedAt {
+				newest = &event
+			}
+		}
+	}
+	if len(errs) == len(relays) {
+		return nil, fmt.Errorf("failed to fetch the current profile: %w", errors.Join(errs...))
+	}
+
+	metadata := make(map[string]any)
+	if newest != nil {
+		if err := json.Unmarshal([]byte(newest.Content), &metadata); err != nil {
+			return nil, fmt.Errorf("current profile %s is not valid JSON: %w", newest.ID, err)
+		}
+	}
+	return metadata, nil
+}
@@ -0,0 +1,62 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"ndmBridge/nostr"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// reactionAddHandler mirrors a Discord reaction on a bridged message as a NIP-25 reaction to its note
+func (b *Bridge) reactionAddHandler(ctx context.Context, s discordSession, r *discordgo.MessageReactionAdd) {
+	if r.UserID == s.cache().User.ID {
+		return
+	}
+	if b.Paused() {
+		log.Printf("Bridging is paused, dropping reaction on message %s", r.MessageID)
+		return
+	}
+
+	bridged, ok := b.events.Get(r.MessageID)
+	if !ok || bridged.EventID == "" {
+		return
+	}
+
+	content, emojiTags := reactionContent(r.Emoji, b.config.Reactions.Map)
+	tags := [][]string{
+		{"e", bridged.EventID, b.config.RelaysForKind(1)[0]},
+		{"p", b.eventAuthor(bridged)},
+		{"k", "1"},
+	}
+	tags = append(tags, emojiTags...)
+
+	event, err := nostr.CreateEvent(nostr.ReactionKind, content, b.config.Nostr.Pubkey, tags)
+	if err != nil {
+		log.Printf("Error creating reaction event: %v", err)
+		return
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, event, b.signer, b.config.RelaysForKind(event.Kind)); err != nil {
+		log.Printf("Error sending reaction event: %v", err)
+		return
+	}
+	log.Printf("Reaction %s on message %s bridged as %s", content, r.MessageID, event.ID)
+}
+
+// reactionContent returns the kind-7 content for a Discord emoji. Emoji found in the mapping, by their
+// unicode character or custom emoji name, use the mapped content. Other unicode emoji are passed
+// through and custom emoji become NIP-30 shortcodes with an emoji tag pointing at the image.
+func reactionContent(emoji discordgo.Emoji, mapping map[string]string) (string, [][]string) {
+	if content, ok := mapping[emoji.Name]; ok {
+		return content, nil
+	}
+	if emoji.ID == "" {
+		return emoji.Name, nil
+	}
+
+	url := discordgo.EndpointEmoji(emoji.ID)
+	if emoji.Animated {
+		url = discordgo.EndpointEmojiAnimated(emoji.ID)
+	}
+	return ":" + emoji.Name + ":", [][]string{{"emoji", emoji.Name, url}}
+}
@@ -0,0 +1,133 @@
+package bridge
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"regexp"
+	"time"
+)
+
+// rebuildPageSize is how many events are requested from a relay at a time while rebuilding the map
+const rebuildPageSize = 500
+
+// rebuildFetchTimeout bounds each page of events fetched from a relay
+const rebuildFetchTimeout = 30 * time.Second
+
+// discordMessageURLRe matches the Discord message link in the proxy tags set by nostr.author_tags
+var discordMessageURLRe = regexp.MustCompile(`^https://discord\.com/channels/[0-9]+/([0-9]+)/([0-9]+)$`)
+
+// RebuildEventMap restores the Discord message to Nostr event map from the notes on the relays, using
+// the NIP-48 proxy tags that link each note to its message. Messages already in the map are kept.
+// Only notes published with nostr.author_tags carry proxy tags. It returns how many entries were added.
+func RebuildEventMap(ctx context.Context, config *utils.Config) (int, error) {
+	if err := config.Prepare(); err != nil {
+		return 0, err
+	}
+	if config.Bridge.EventMapFile == "" {
+		return 0, errors.New("bridge.event_map_file is not set in the config")
+	}
+	if err := setupRelayConnections(config); err != nil {
+		return 0, err
+	}
+
+	events, err := loadEventMap(config.Bridge.EventMapFile)
+	if err != nil {
+		return 0, fmt.Errorf("error loading event map: %w", err)
+	}
+
+	// Notes of Discord users with their own key are published under their pubkeys. Keys derived from
+	// nostr.author_seed can't be listed without their user IDs, so their notes are only found when
+	// the user is also in nostr.author_keys.
+	authors, err := newAuthorKeys(config, nil)
+	if err != nil {
+		return 0, err
+	}
+	pubkeys := bridgePubkeys(config, authors)
+
+	found := make(map[string]bridgedEvent)
+	for _, relayURL := range config.RelaysForKind(1) {
+		for _, pubkey := range pubkeys {
+			author := pubkey
+			if pubkey == config.Nostr.Pubkey {
+				author = ""
+			}
+			if err := fetchBridgedNotes(ctx, relayURL, pubkey, author, found); err != nil {
+				log.Printf("Error fetching notes of %s from %s: %v", pubkey, relayURL, err)
+			}
+		}
+	}
+	nostr.CloseRelays()
+
+	added, err := events.addMissing(found)
+	if err != nil {
+		return added, err
+	}
+	log.Printf("Found %d bridged notes on the relays, added %d to the event map", len(found), added)
+	return added, nil
+}
+
+// fetchBridgedNotes pages through the relay's notes by pubkey, newest first, adding the ones with a
+// Discord proxy tag to found by message ID. Their entries record author, empty for nostr.pubkey.
+func fetchBridgedNotes(ctx context.Context, relayURL, pubkey, author string, found map[string]bridgedEvent) error {
+	seen := make(map[string]bool)
+	filter := nostr.Filter{Kinds: []int{1}, Authors: []string{pubkey}, Limit: rebuildPageSize}
+	for {
+		pageCtx, cancel := context.WithTimeout(ctx, rebuildFetchTimeout)
+		page, err := nostr.FetchEvents(pageCtx, relayURL, filter)
+		cancel()
+		if err != nil {
+			return err
+		}
+
+		// Pages overlap by a second so notes sharing a timestamp aren't missed, so stop once nothing is new
+		fresh := 0
+		for _, event := range page {
+			if seen[event.ID] {
+				continue
+			}
+			seen[event.ID] = true
+			fresh++
+
+			if filter.Until == 0 || event.CreatedAt < filter.Until {
+				filter.Until = event.CreatedAt
+			}
+			if event.Pubkey != pubkey {
+				continue
+			}
+			if channelID, messageID, ok := proxiedMessage(event); ok {
+				found[messageID] = bridgedEvent{EventID: event.ID, RootID: rootTag(event), ChannelID: channelID, Pubkey: author}
+			}
+		}
+		if fresh == 0 {
+			log.Printf("Fetched %d notes from %s", len(seen), relayURL)
+			return nil
+		}
+	}
+}
+
+// proxiedMessage returns the Discord channel and message the note's proxy tag links to
+func proxiedMessage(event nostr.NostrEvent) (string, string, bool) {
+	for _, tag := range event.Tags {
+		if len(tag) < 3 || tag[0] != "proxy" || tag[2] != "web" {
+			continue
+		}
+		if match := discordMessageURLRe.FindStringSubmatch(tag[1]); match != nil {
+			return match[1], match[2], true
+		}
+	}
+	return "", "", false
+}
+
+// rootTag returns the NIP-10 root of the thread the note replies in, or "" for a root note
+func rootTag(event nostr.NostrEvent) string {
+	for _, tag := range event.Tags {
+		if len(tag) >= 4 && tag[0] == "e" && tag[3] == "root" {
+			return tag[1]
+		}
+	}
+	return ""
+}
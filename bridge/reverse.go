@@ -0,0 +1,228 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"slices"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// maxSeenEvents is how many event IDs the reverse bridge remembers to skip duplicates
+const maxSeenEvents = 1000
+
+// maxFutureSkew is how far ahead of the local clock an event's timestamp may advance the subscription
+const maxFutureSkew = 5 * time.Minute
+
+// reverseReconnectDelay is how long to wait before resubscribing after a relay connection drops
+const reverseReconnectDelay = 10 * time.Second
+
+// reverseBridge mirrors Nostr replies to the bridge's pubkey back into Discord
+type reverseBridge struct {
+	config *utils.Config
+	events *eventMap
+	outbox *discordOutbox
+	// authors are the identities of Discord users bridged under their own key, nil when there are none
+	authors *authorKeys
+
+	template *template.Template
+
+	mu       sync.Mutex
+	lastSeen int64
+	seen     map[string]bool
+	// seenOrder lists the seen event IDs oldest first, so the oldest is forgotten once seen is full
+	seenOrder []string
+}
+
+// replyData is the data available to the reverse.template used for Nostr replies
+type replyData struct {
+	Author    string // Abbreviated pubkey of the reply's author
+	Pubkey    string
+	Content   string
+	EventID   string
+	Link      string // njump.me link to the reply
+	CreatedAt time.Time
+}
+
+// startReverseBridge subscribes to every configured relay for replies and posts them to Discord until ctx is done
+func startReverseBridge(ctx context.Context, b *Bridge) {
+	config := b.config
+	rb := &reverseBridge{
+		config:  config,
+		events:  b.events,
+		outbox:  startDiscordOutbox(ctx, b.discord(), b.posted),
+		authors: b.authors,
+		// Prepare has already checked that the template parses
+		template: template.Must(template.New("reply").Parse(config.Reverse.Template)),
+		// Only mirror replies created after the bridge started, or within reverse.filter.since before
+		lastSeen: time.Now().Add(-config.Reverse.Filter.Since).Unix(),
+		seen:     make(map[string]bool),
+	}
+
+	for _, relayURL := range config.Nostr.Relays {
+		go rb.run(ctx, relayURL)
+	}
+	log.Printf("Reverse bridge started on %d relays", len(config.Nostr.Relays))
+}
+
+// run keeps a subscription open on the relay, resuming from the last seen timestamp after a reconnect
+func (rb *reverseBridge) run(ctx context.Context, relayURL string) {
+	for ctx.Err() == nil {
+		filter := reverseFilter(rb.config, bridgePubkeys(rb.config, rb.authors))
+		filter.Since = rb.since()
+
+		err := nostr.Subscribe(ctx, relayURL, "ndmbridge-replies", filter, rb.handleEvent)
+		log.Printf("Reverse bridge subscription to %s ended: %v", relayURL, err)
+
+		select {
+		case <-time.After(reverseReconnectDelay):
+		case <-ctx.Done():
+		}
+	}
+}
+
+// reverseFilter builds the subscription filter from reverse.filter. Without kinds it asks for notes,
+// and without any tag or author conditions for events tagging one of the bridge's pubkeys such as
+// replies. Zap receipts are added when zaps are announced.
+func reverseFilter(config *utils.Config, pubkeys []string) nostr.Filter {
+	configured := config.Reverse.Filter
+	filter := nostr.Filter{
+		Kinds:   slices.Clone(configured.Kinds),
+		Authors: configured.Authors,
+		ETags:   configured.E,
+		PTags:   configured.P,
+		TTags:   configured.T,
+	}
+	if len(filter.Kinds) == 0 {
+		filter.Kinds = []int{1}
+	}
+	if config.Reverse.Zaps && !slices.Contains(filter.Kinds, nostr.ZapReceiptKind) {
+		filter.Kinds = append(filter.Kinds, nostr.ZapReceiptKind)
+	}
+	if len(filter.Authors) == 0 && len(filter.ETags) == 0 && len(filter.PTags) == 0 && len(filter.TTags) == 0 {
+		filter.PTags = pubkeys
+	}
+	return filter
+}
+
+// since returns the timestamp the next subscription should start from
+func (rb *reverseBridge) since() int64 {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+	return rb.lastSeen
+}
+
+// handleEvent posts a reply from Nostr into the Discord channel, skipping duplicates and our own events
+func (rb *reverseBridge) handleEvent(event nostr.NostrEvent) {
+	rb.mu.Lock()
+	if rb.seen[event.ID] || event.Pubkey == rb.config.Nostr.Pubkey || rb.authors != nil && rb.authors.has(event.Pubkey) {
+		rb.mu.Unlock()
+		return
+	}
+	if len(rb.seenOrder) >= maxSeenEvents {
+		delete(rb.seen, rb.seenOrder[0])
+		rb.seenOrder = rb.seenOrder[1:]
+	}
+	rb.seen[event.ID] = true
+	rb.seenOrder = append(rb.seenOrder, event.ID)
+	// A timestamp far in the future would make resubscribing skip every reply until then
+	if createdAt := min(event.CreatedAt, time.Now().Add(maxFutureSkew).Unix()); createdAt > rb.lastSeen {
+		rb.lastSeen = createdAt
+	}
+	rb.mu.Unlock()
+
+	if event.Kind == nostr.ZapReceiptKind {
+		rb.handleZap(event)
+		return
+	}
+
+	data := replyData{
+		Author:    shortPubkey(event.Pubkey),
+		Pubkey:    event.Pubkey,
+		Content:   event.Content,
+		EventID:   event.ID,
+		Link:      "https://njump.me/" + event.ID,
+		CreatedAt: time.Unix(event.CreatedAt, 0),
+	}
+	var sb strings.Builder
+	if err := rb.template.Execute(&sb, data); err != nil {
+		log.Printf("Error formatting Nostr reply %s: %v", event.ID, err)
+		return
+	}
+
+	message := &discordgo.MessageSend{Content: sb.String()}
+	if rb.config.Reverse.Embed {
+		message = &discordgo.MessageSend{Embeds: []*discordgo.MessageEmbed{{
+			Author:      &discordgo.MessageEmbedAuthor{Name: data.Author, URL: "https://njump.me/" + event.Pubkey},
+			Description: sb.String(),
+			URL:         data.Link,
+			Title:       "Reply on Nostr",
+			Timestamp:   data.CreatedAt.Format(time.RFC3339),
+		}}}
+	}
+
+	// Replies are posted to the first watched channel
+	rb.outbox.post(rb.config.Discord.Channels[0].ID, message, "Nostr reply "+event.ID)
+}
+
+// handleZap announces a zap receipt in Discord, replying to the bridged message when the zapped note is known
+func (rb *reverseBridge) handleZap(event nostr.NostrEvent) {
+	zap, err := nostr.ParseZapReceipt(event)
+	if err != nil {
+		log.Printf("Ignoring invalid zap receipt %s: %v", event.ID, err)
+		return
+	}
+	// Anyone can publish a receipt, only the providers behind the bridge's lightning addresses are trusted
+	if !slices.Contains(rb.config.Reverse.ZapProviders, zap.Provider) {
+		log.Printf("Ignoring zap receipt %s from untrusted provider %s", event.ID, zap.Provider)
+		return
+	}
+
+	message := fmt.Sprintf("⚡ %d sats from %s", zap.AmountMsat/1000, shortPubkey(zap.Sender))
+	if zap.Comment != "" {
+		message += ": " + zap.Comment
+	}
+
+	channelID := rb.config.Discord.Channels[0].ID
+	var reference *discordgo.MessageReference
+	messageID, bridged, ok := rb.events.FindMessage(zap.EventID)
+	switch {
+	case ok:
+		// The zap must go to whoever the note was published under
+		author := bridged.Pubkey
+		if author == "" {
+			author = rb.config.Nostr.Pubkey
+		}
+		if zap.Recipient != author {
+			log.Printf("Ignoring zap receipt %s, it zaps %s instead of the author of note %s", event.ID, zap.Recipient, zap.EventID)
+			return
+		}
+		if bridged.ChannelID != "" {
+			channelID = bridged.ChannelID
+			reference = &discordgo.MessageReference{MessageID: messageID, ChannelID: channelID}
+		}
+	case zap.Recipient != rb.config.Nostr.Pubkey && (rb.authors == nil || !rb.authors.has(zap.Recipient)):
+		log.Printf("Ignoring zap receipt %s for %s, which isn't one of the bridge's pubkeys", event.ID, zap.Recipient)
+		return
+	}
+
+	rb.outbox.post(channelID, &discordgo.MessageSend{
+		Content:   message,
+		Reference: reference,
+	}, fmt.Sprintf("Zap %s of %d msat", event.ID, zap.AmountMsat))
+}
+
+// shortPubkey abbreviates a hex pubkey for display
+func shortPubkey(pubkey string) string {
+	if len(pubkey) <= 16 {
+		return pubkey
+	}
+	return pubkey[:8] + "…" + pubkey[len(pubkey)-8:]
+}
@@ -0,0 +1,40 @@
+package bridge
+
+import (
+	"context"
+	"ndmBridge/nostr"
+	"strings"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestReverseBridgeFutureTimestamp(t *testing.T) {
+	b := newTestBridge()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rb := &reverseBridge{
+		config:   b.config,
+		outbox:   &discordOutbox{session: newFakeSession(), posted: b.posted, queue: make(chan discordPost, outboxSize), done: ctx.Done()},
+		template: template.Must(template.New("reply").Parse("{{.Content}}")),
+		lastSeen: time.Now().Unix(),
+		seen:     make(map[string]bool),
+	}
+
+	// An event dated a year ahead is still mirrored, but doesn't move the subscription past now
+	future := time.Now().AddDate(1, 0, 0).Unix()
+	rb.handleEvent(nostr.NostrEvent{ID: strings.Repeat("a", 64), Pubkey: strings.Repeat("c", 64), CreatedAt: future, Kind: 1, Content: "from the future"})
+	if n := len(rb.outbox.queue); n != 1 {
+		t.Fatalf("handleEvent() queued %d posts, want 1", n)
+	}
+	if since, limit := rb.since(), time.Now().Add(maxFutureSkew).Unix(); since > limit {
+		t.Errorf("since() = %d after an event created at %d, want at most %d", since, future, limit)
+	}
+
+	// Timestamps within the allowed skew advance it as usual
+	soon := time.Now().Add(time.Minute).Unix()
+	rb.handleEvent(nostr.NostrEvent{ID: strings.Repeat("b", 64), Pubkey: strings.Repeat("c", 64), CreatedAt: soon, Kind: 1, Content: "slightly ahead"})
+	if since := rb.since(); since < soon {
+		t.Errorf("since() = %d after an event created at %d, want at least %d", since, soon, soon)
+	}
+}
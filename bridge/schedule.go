@@ -0,0 +1,129 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// scheduler holds incoming messages and releases them in order once their publish delay has passed
+// and the posting hours have begun. Held messages live in memory only, so they are lost on shutdown.
+type scheduler struct {
+	bridge *Bridge
+	delay  time.Duration
+	// start and end are the HH:MM local posting hours, empty to post at any time
+	start, end string
+
+	mu    sync.Mutex
+	queue []scheduledMessage
+	wake  chan struct{}
+}
+
+// scheduledMessage is a received message waiting for its release time
+type scheduledMessage struct {
+	ctx       context.Context
+	session   discordSession
+	message   *discordgo.MessageCreate
+	releaseAt time.Time
+}
+
+// startScheduler creates the scheduler and releases held messages until ctx is done
+func startScheduler(ctx context.Context, b *Bridge) *scheduler {
+	sc := &scheduler{
+		bridge: b,
+		delay:  b.config.Schedule.Delay,
+		start:  b.config.Schedule.Start,
+		end:    b.config.Schedule.End,
+		wake:   make(chan struct{}, 1),
+	}
+	go sc.run(ctx)
+	log.Printf("Scheduled posting enabled with a delay of %s", sc.delay)
+	return sc
+}
+
+// add holds the message until its release time
+func (sc *scheduler) add(ctx context.Context, s discordSession, m *discordgo.MessageCreate) {
+	releaseAt := sc.releaseTime(time.Now())
+
+	sc.mu.Lock()
+	sc.queue = append(sc.queue, scheduledMessage{ctx: ctx, session: s, message: m, releaseAt: releaseAt})
+	pending := len(sc.queue)
+	sc.mu.Unlock()
+
+	select {
+	case sc.wake <- struct{}{}:
+	default:
+	}
+	log.Printf("Message %s scheduled for %s, %d messages pending", m.ID, releaseAt.Format(time.RFC3339), pending)
+}
+
+// run releases the held messages oldest first. Release times never decrease, so waiting for the
+// oldest one keeps the Discord order.
+func (sc *scheduler) run(ctx context.Context) {
+	for {
+		sc.mu.Lock()
+		if len(sc.queue) == 0 {
+			sc.mu.Unlock()
+			select {
+			case <-sc.wake:
+				continue
+			case <-ctx.Done():
+				return
+			}
+		}
+		next := sc.queue[0]
+		sc.mu.Unlock()
+
+		select {
+		case <-time.After(time.Until(next.releaseAt)):
+		case <-ctx.Done():
+			sc.mu.Lock()
+			dropped := len(sc.queue)
+			sc.mu.Unlock()
+			log.Printf("Dropping %d scheduled messages that were not published yet", dropped)
+			return
+		}
+
+		sc.mu.Lock()
+		sc.queue = sc.queue[1:]
+		sc.mu.Unlock()
+
+		sc.bridge.inFlight.Add(1)
+		sc.bridge.handleMessage(next.ctx, next.session, next.message)
+		sc.bridge.inFlight.Done()
+	}
+}
+
+// releaseTime returns when a message received at the given time is published: after the delay,
+// moved to the start of the next posting hours when that falls outside them
+func (sc *scheduler) releaseTime(received time.Time) time.Time {
+	release := received.Add(sc.delay)
+	if sc.start == "" || inPostingHours(release, sc.start, sc.end) {
+		return release
+	}
+	return nextClockTime(release, sc.start)
+}
+
+// inPostingHours reports whether t lies within the HH:MM posting hours from start to end. Hours
+// ending before they start span midnight, e.g. 22:00 to 06:00.
+func inPostingHours(t time.Time, start, end string) bool {
+	from, err := time.Parse("15:04", start)
+	if err != nil {
+		return true
+	}
+	to, err := time.Parse("15:04", end)
+	if err != nil {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	first := from.Hour()*60 + from.Minute()
+	last := to.Hour()*60 + to.Minute()
+	if first <= last {
+		return minute >= first && minute < last
+	}
+	return minute >= first || minute < last
+}
@@ -0,0 +1,31 @@
+package bridge
+
+import "github.com/bwmarrin/discordgo"
+
+// discordSession is the part of the Discord session the event handlers use, so they can be run
+// against a fake session in tests
+type discordSession interface {
+	Channel(channelID string, options ...discordgo.RequestOption) (*discordgo.Channel, error)
+	ChannelMessagesPinned(channelID string, options ...discordgo.RequestOption) ([]*discordgo.Message, error)
+	ChannelMessageSendComplex(channelID string, data *discordgo.MessageSend, options ...discordgo.RequestOption) (*discordgo.Message, error)
+	GuildMember(guildID, userID string, options ...discordgo.RequestOption) (*discordgo.Member, error)
+	GuildRoles(guildID string, options ...discordgo.RequestOption) ([]*discordgo.Role, error)
+	MessageReactionAdd(channelID, messageID, emojiID string, options ...discordgo.RequestOption) error
+	User(userID string, options ...discordgo.RequestOption) (*discordgo.User, error)
+	// cache returns the state cache of the session, which also holds the bot's own user
+	cache() *discordgo.State
+}
+
+// liveSession is a discordSession backed by a connection to Discord
+type liveSession struct {
+	*discordgo.Session
+}
+
+func (s liveSession) cache() *discordgo.State {
+	return s.State
+}
+
+// discord returns the bridge's Discord session for the event handlers
+func (b *Bridge) discord() discordSession {
+	return liveSession{b.session}
+}
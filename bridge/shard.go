@@ -0,0 +1,21 @@
+package bridge
+
+import (
+	"log"
+	"strconv"
+)
+
+// onShard reports whether the guild belongs to the session's shard. Discord sends the events of a guild
+// only to the shard (guild_id >> 22) % shard_count, so with sharding each bridge only catches up on
+// the channels of its own guilds.
+func (b *Bridge) onShard(guildID string) bool {
+	if b.session.ShardCount <= 1 {
+		return true
+	}
+	id, err := strconv.ParseUint(guildID, 10, 64)
+	if err != nil {
+		log.Printf("Cannot determine the shard of guild %q: %v", guildID, err)
+		return false
+	}
+	return int((id>>22)%uint64(b.session.ShardCount)) == b.session.ShardID
+}
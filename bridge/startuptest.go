@@ -0,0 +1,45 @@
+package bridge
+
+import (
+	"context"
+	"log"
+	"ndmBridge/nostr"
+	"strconv"
+)
+
+// startupTestContent is the content of the test note published at startup
+const startupTestContent = "ndmBridge startup test"
+
+// publishStartupTest publishes a test note to the startup test relay, checking the whole sign and
+// publish path with the real key, and optionally requests its deletion. Failures are only logged.
+func (b *Bridge) publishStartupTest(ctx context.Context) {
+	relays := []string{b.config.Nostr.StartupTest.Relay}
+
+	event, err := nostr.CreateNostrEvent(startupTestContent, b.config.Nostr.Pubkey, nil)
+	if err != nil {
+		log.Printf("Startup test failed: error creating test note: %v", err)
+		return
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, event, b.signer, relays); err != nil {
+		log.Printf("Startup test failed: test note %s was not accepted by %s: %v", event.ID, relays[0], err)
+		return
+	}
+	log.Printf("Startup test passed: test note %s accepted by %s", event.ID, relays[0])
+
+	if !b.config.Nostr.StartupTest.Delete {
+		return
+	}
+	deletion, err := nostr.CreateEvent(nostr.DeletionKind, "", b.config.Nostr.Pubkey, [][]string{
+		{"e", event.ID},
+		{"k", strconv.Itoa(event.Kind)},
+	})
+	if err != nil {
+		log.Printf("Error creating deletion request for test note %s: %v", event.ID, err)
+		return
+	}
+	if _, err := nostr.SignAndSendEvent(ctx, deletion, b.signer, relays); err != nil {
+		log.Printf("Error requesting deletion of test note %s from %s: %v", event.ID, relays[0], err)
+		return
+	}
+	log.Printf("Deletion of test note %s requested from %s", event.ID, relays[0])
+}
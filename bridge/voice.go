@@ -0,0 +1,108 @@
+package bridge
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// voiceAnnounceCooldown keeps a voice channel that empties and fills again from being announced repeatedly
+const voiceAnnounceCooldown = 10 * time.Minute
+
+// voiceAnnouncer publishes notes when a configured voice channel session starts or a stage goes live
+type voiceAnnouncer struct {
+	bridge *Bridge
+
+	mu        sync.Mutex
+	announced map[string]time.Time
+}
+
+// newVoiceAnnouncer creates an announcer for the configured voice channels
+func newVoiceAnnouncer(b *Bridge) *voiceAnnouncer {
+	return &voiceAnnouncer{bridge: b, announced: make(map[string]time.Time)}
+}
+
+// voiceStateUpdateHandler announces a voice session when the first member joins a configured channel
+func (va *voiceAnnouncer) voiceStateUpdateHandler(ctx context.Context, s discordSession, v *discordgo.VoiceStateUpdate) {
+	if v.ChannelID == "" || (v.BeforeUpdate != nil && v.BeforeUpdate.ChannelID == v.ChannelID) || !va.watched(v.ChannelID) {
+		return
+	}
+
+	// The state already includes the member who just joined
+	guild, err := s.cache().Guild(v.GuildID)
+	if err != nil {
+		log.Printf("Error looking up guild %s for voice state: %v", v.GuildID, err)
+		return
+	}
+	members := 0
+	for _, state := range guild.VoiceStates {
+		if state.ChannelID == v.ChannelID {
+			members++
+		}
+	}
+	if members != 1 {
+		return
+	}
+	// Stages are announced when they go live instead, not when the host joins
+	if channel := lookupChannel(s, v.ChannelID); channel == nil || channel.Type == discordgo.ChannelTypeGuildStageVoice {
+		return
+	}
+
+	va.announce(ctx, s, v.ChannelID, func(name string) string {
+		return fmt.Sprintf("🔊 Voice chat started in #%s, join in!", name)
+	})
+}
+
+// stageInstanceCreateHandler announces a stage in a configured channel going live
+func (va *voiceAnnouncer) stageInstanceCreateHandler(ctx context.Context, s discordSession, e *discordgo.StageInstanceEventCreate) {
+	if !va.watched(e.ChannelID) {
+		return
+	}
+
+	va.announce(ctx, s, e.ChannelID, func(name string) string {
+		if e.Topic != "" {
+			return fmt.Sprintf("🔴 Live in #%s now: %s", name, e.Topic)
+		}
+		return fmt.Sprintf("🔴 Live in #%s now", name)
+	})
+}
+
+// watched reports whether announcements are configured for the channel
+func (va *voiceAnnouncer) watched(channelID string) bool {
+	return slices.Contains(va.bridge.config.Voice.Channels, channelID)
+}
+
+// announce publishes the note built from the channel name, unless the channel was announced recently
+func (va *voiceAnnouncer) announce(ctx context.Context, s discordSession, channelID string, content func(name string) string) {
+	b := va.bridge
+	if b.Paused() {
+		log.Printf("Bridging is paused, not announcing voice channel %s", channelID)
+		return
+	}
+
+	va.mu.Lock()
+	if last, ok := va.announced[channelID]; ok && time.Since(last) < voiceAnnounceCooldown {
+		va.mu.Unlock()
+		log.Printf("Voice channel %s was announced at %s, skipping", channelID, last.Format(time.Kitchen))
+		return
+	}
+	va.announced[channelID] = time.Now()
+	va.mu.Unlock()
+
+	name := channelID
+	if channel := lookupChannel(s, channelID); channel != nil && channel.Name != "" {
+		name = channel.Name
+	}
+
+	event, _, err := b.publishNote(ctx, content(name), nil)
+	if err != nil {
+		log.Printf("Error publishing announcement for voice channel %s: %v", channelID, err)
+		return
+	}
+	log.Printf("Voice channel %s announced as %s", channelID, event.ID)
+}
@@ -0,0 +1,86 @@
+package bridge
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"ndmBridge/nostr"
+	"net/http"
+	"time"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// webhookClient posts publish results, bounded so a slow endpoint can't hold up shutdown
+var webhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// publishReport describes the publish results of a bridged message's note. It is the JSON body
+// posted to the webhook and kept for the API.
+type publishReport struct {
+	MessageID string        `json:"message_id"`
+	ChannelID string        `json:"channel_id"`
+	EventID   string        `json:"event_id"`
+	Published bool          `json:"published"`
+	Relays    []relayReport `json:"relays"`
+	Timestamp time.Time     `json:"timestamp"`
+}
+
+// relayReport is the outcome on one relay, Error is empty when the relay accepted the event
+type relayReport struct {
+	Relay    string `json:"relay"`
+	Accepted bool   `json:"accepted"`
+	Error    string `json:"error,omitempty"`
+}
+
+// newPublishReport summarizes the relay results of publishing the message's note
+func newPublishReport(m *discordgo.MessageCreate, eventID string, results []nostr.RelayResult) *publishReport {
+	report := &publishReport{
+		MessageID: m.ID,
+		ChannelID: m.ChannelID,
+		EventID:   eventID,
+		Relays:    make([]relayReport, 0, len(results)),
+		Timestamp: time.Now().UTC(),
+	}
+	for _, result := range results {
+		r := relayReport{Relay: result.Relay, Accepted: result.Err == nil}
+		if result.Err != nil {
+			r.Error = result.Err.Error()
+		} else {
+			report.Published = true
+		}
+		report.Relays = append(report.Relays, r)
+	}
+	return report
+}
+
+// notifyWebhook posts the publish report to the configured webhook in the background. Failures are
+// logged and never affect bridging.
+func (b *Bridge) notifyWebhook(report *publishReport) {
+	b.inFlight.Add(1)
+	go func() {
+		defer b.inFlight.Done()
+		if err := postWebhook(b.config.Bridge.WebhookURL, report); err != nil {
+			log.Printf("Error notifying webhook about message %s: %v", report.MessageID, err)
+		}
+	}()
+}
+
+// postWebhook sends the payload as JSON and expects a 2xx response
+func postWebhook(url string, payload *publishReport) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+
+	resp, err := webhookClient.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to post to webhook: %w", err)
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook answered with status %s", resp.Status)
+	}
+	return nil
+}
@@ -13,7 +13,7 @@ require (
 require (
 	github.com/btcsuite/btcd/btcec/v2 v2.3.4
 	github.com/gorilla/websocket v1.4.2
-	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
+	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
 	golang.org/x/sys v0.0.0-20201119102817-f84b799fce68 // indirect
 	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Patterns picking the IDs out of log messages for the JSON fields
+var (
+	eventIDRe   = regexp.MustCompile(`\b[0-9a-f]{64}\b`)
+	relayURLRe  = regexp.MustCompile(`wss?://[^\s,;)]+`)
+	messageIDRe = regexp.MustCompile(`(?i)\bmessage (?:id )?([0-9]{17,20})\b`)
+)
+
+// jsonLogEntry is one line of JSON log output. The IDs are empty when the message names none.
+type jsonLogEntry struct {
+	Time         string `json:"time"`
+	Level        string `json:"level"`
+	Msg          string `json:"msg"`
+	EventID      string `json:"event_id,omitempty"`
+	Relay        string `json:"relay,omitempty"`
+	DiscordMsgID string `json:"discord_msg_id,omitempty"`
+}
+
+// jsonLogWriter turns each log line into a JSON object for log ingestion. The standard logger writes
+// every entry in one call, so each write is one entry, even when the message spans several lines.
+type jsonLogWriter struct {
+	out io.Writer
+}
+
+// Write implements io.Writer
+func (w *jsonLogWriter) Write(p []byte) (int, error) {
+	msg := strings.TrimSuffix(string(p), "\n")
+	entry := jsonLogEntry{
+		Time:    time.Now().Format(time.RFC3339),
+		Level:   logLevel(msg),
+		Msg:     msg,
+		EventID: eventIDRe.FindString(msg),
+		Relay:   strings.TrimRight(relayURLRe.FindString(msg), ":."),
+	}
+	if match := messageIDRe.FindStringSubmatch(msg); match != nil {
+		entry.DiscordMsgID = match[1]
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := w.out.Write(append(line, '\n')); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// logLevel derives the level from the wording of the message, as the logger has no levels
+func logLevel(msg string) string {
+	lower := strings.ToLower(msg)
+	switch {
+	case strings.HasPrefix(lower, "error") || strings.Contains(lower, "failed"):
+		return "error"
+	case strings.HasPrefix(lower, "warning") || strings.Contains(lower, "exceeds"):
+		return "warn"
+	}
+	return "info"
+}
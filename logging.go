@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"ndmBridge/utils"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+)
+
+// logFile is a log destination that can be reopened, so external tools can rotate it
+type logFile struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+// openLogFile opens the file for appending, creating it if needed
+func openLogFile(path string) (*logFile, error) {
+	l := &logFile{path: path}
+	if err := l.reopen(); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Write implements io.Writer
+func (l *logFile) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file.Write(p)
+}
+
+// reopen closes the current file and opens the path again
+func (l *logFile) reopen() error {
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("cannot open log file: %w", err)
+	}
+
+	l.mu.Lock()
+	old := l.file
+	l.file = file
+	l.mu.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+	return nil
+}
+
+// setupLogging points the logger at the configured log file, reopening it on SIGHUP, and switches
+// to JSON lines when configured
+func setupLogging(config *utils.Config) error {
+	var out io.Writer = os.Stderr
+	if config.Bridge.LogFile != "" {
+		lf, err := openLogFile(config.Bridge.LogFile)
+		if err != nil {
+			return err
+		}
+		out = lf
+		if config.Bridge.LogStderr {
+			out = io.MultiWriter(os.Stderr, lf)
+		}
+		reopenOnHangup(lf)
+	}
+
+	if config.Bridge.LogFormat == utils.LogFormatJSON {
+		out = &jsonLogWriter{out: out}
+		log.SetFlags(0)
+	}
+	log.SetOutput(out)
+
+	if config.Bridge.LogFile != "" {
+		log.Printf("Logging to %s", config.Bridge.LogFile)
+	}
+	return nil
+}
+
+// reopenOnHangup reopens the log file whenever the process receives SIGHUP
+func reopenOnHangup(lf *logFile) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := lf.reopen(); err != nil {
+				fmt.Fprintf(os.Stderr, "Error reopening log file: %v\n", err)
+				continue
+			}
+			log.Println("Log file reopened")
+		}
+	}()
+}
@@ -1,44 +1,84 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
-	"ndmBridge/nostr"
+	"ndmBridge/bridge"
 	"ndmBridge/utils"
 	"os"
 	"os/signal"
 	"syscall"
-
-	"github.com/bwmarrin/discordgo"
 )
 
 func main() {
-	// Load configuration from config.yml
-	config, err := utils.LoadConfig("config.yml")
+	// `ndmBridge status` queries a running bridge instead of starting one, `ndmBridge rebuild-map`
+	// restores the event map from the relays and `ndmBridge identity <user id>` prints the Nostr
+	// identity a Discord user is bridged under
+	args := os.Args[1:]
+	mode, userID := "", ""
+	if len(args) > 0 && (args[0] == "status" || args[0] == "rebuild-map") {
+		mode = args[0]
+		args = args[1:]
+	}
+	if len(args) > 0 && args[0] == "identity" {
+		if len(args) < 2 {
+			log.Fatalf("Usage: ndmBridge identity <discord user id> [config files]")
+		}
+		mode, userID = args[0], args[1]
+		args = args[2:]
+	}
+
+	// Load configuration from config.yml, or from the files given as arguments in order
+	configFiles := args
+	if len(configFiles) == 0 {
+		configFiles = []string{"config.yml"}
+	}
+	config, err := utils.LoadConfig(configFiles...)
 	if err != nil {
 		log.Fatalf("Error loading config: %v", err)
 	}
-	log.Println("Config loaded successfully")
 
-	// Create a new Discord session using the provided bot token.
-	dg, err := discordgo.New("Bot " + config.Discord.Token)
-	if err != nil {
-		log.Fatalf("Error creating Discord session: %v", err)
+	switch mode {
+	case "status":
+		if err := printStatus(config); err != nil {
+			log.Fatalf("Error getting status: %v", err)
+		}
+		return
+	case "rebuild-map":
+		added, err := bridge.RebuildEventMap(context.Background(), config)
+		if err != nil {
+			log.Fatalf("Error rebuilding event map: %v", err)
+		}
+		fmt.Printf("Added %d messages to the event map\n", added)
+		return
+	case "identity":
+		identity, err := bridge.AuthorIdentity(config, userID)
+		if err != nil {
+			log.Fatalf("Error looking up identity: %v", err)
+		}
+		fmt.Printf("%s\n%s\n", identity.Npub, identity.Pubkey)
+		if identity.Shared {
+			fmt.Println("This user has no identity of their own and is bridged under nostr.pubkey")
+		}
+		return
 	}
-	log.Println("Discord session created successfully")
+	log.Println("Config loaded successfully")
 
-	// Add the message handler
-	dg.AddHandler(func(s *discordgo.Session, m *discordgo.MessageCreate) {
-		log.Printf("New message received: %s", m.Content)
-		messageCreateHandler(s, m, config)
-	})
+	if err := setupLogging(config); err != nil {
+		log.Fatalf("Error setting up logging: %v", err)
+	}
 
-	// Open a WebSocket connection to Discord
-	err = dg.Open()
+	b, err := bridge.New(bridge.Options{Config: config})
 	if err != nil {
-		log.Fatalf("Error opening connection: %v", err)
+		log.Fatalf("Error creating bridge: %v", err)
+	}
+
+	if err := b.Start(context.Background()); err != nil {
+		log.Fatalf("Error starting bridge: %v", err)
 	}
-	defer dg.Close()
+
+	handlePauseSignal(b)
 
 	fmt.Println("Bot is now running. Press CTRL+C to exit.")
 	log.Println("Bot is now running")
@@ -50,31 +90,6 @@ func main() {
 
 	fmt.Println("Shutting down bot.")
 	log.Println("Shutting down bot")
-}
-
-// messageCreateHandler handles incoming Discord messages
-func messageCreateHandler(s *discordgo.Session, m *discordgo.MessageCreate, config *utils.Config) {
-	if m.Author.ID == s.State.User.ID {
-		log.Println("Ignoring message from bot itself")
-		return
-	}
-
-	if m.ChannelID == config.Discord.ChannelID {
-		content := nostr.PrepareMessageContent(m)
-		log.Printf("Prepared content for Nostr event: %s", content)
-
-		event, err := nostr.CreateNostrEvent(content, config.Nostr.Pubkey)
-		if err != nil {
-			log.Printf("Error creating Nostr event: %v", err)
-			return
-		}
-		log.Printf("Nostr event created: %+v", event)
 
-		err = nostr.SignAndSendEvent(event, config.Nostr.PrivKey, config.Nostr.RelayURL)
-		if err != nil {
-			log.Printf("Error sending Nostr event: %v", err)
-		} else {
-			log.Println("Nostr event sent successfully")
-		}
-	}
+	b.Stop()
 }
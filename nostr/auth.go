@@ -0,0 +1,92 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// AuthKind is the NIP-42 client authentication event kind
+const AuthKind = 22242
+
+var (
+	authSigner Signer
+	authPubkey string
+	authRelays = make(map[string]bool)
+)
+
+// SetAuth sets the signer used to answer NIP-42 AUTH challenges and the relays trusted with the answer.
+// Challenges from relays that are not listed are ignored. It must be called before publishing starts.
+func SetAuth(signer Signer, pubkey string, trustedRelays []string) {
+	authSigner = signer
+	authPubkey = pubkey
+	authRelays = make(map[string]bool, len(trustedRelays))
+	for _, relay := range trustedRelays {
+		authRelays[relay] = true
+	}
+}
+
+// authChallenge returns the challenge of an AUTH frame
+func authChallenge(message []byte) (string, bool) {
+	var frame []json.RawMessage
+	if err := json.Unmarshal(message, &frame); err != nil || len(frame) < 2 {
+		return "", false
+	}
+
+	var label, challenge string
+	if json.Unmarshal(frame[0], &label) != nil || label != "AUTH" {
+		return "", false
+	}
+	if json.Unmarshal(frame[1], &challenge) != nil {
+		return "", false
+	}
+	return challenge, true
+}
+
+// isAuthRequired reports whether the relay rejected an event because the connection isn't authenticated
+func isAuthRequired(reason string) bool {
+	return strings.HasPrefix(reason, "auth-required:")
+}
+
+// authenticate answers an AUTH challenge when the relay is trusted. The caller must hold the lock.
+func (rc *relayConn) authenticate(ctx context.Context, challenge string) error {
+	if authSigner == nil || !authRelays[rc.url] {
+		log.Printf("Ignoring AUTH challenge from untrusted relay %s", rc.url)
+		return nil
+	}
+
+	event := &NostrEvent{
+		Pubkey:    authPubkey,
+		CreatedAt: time.Now().Unix(),
+		Kind:      AuthKind,
+		Tags:      [][]string{{"relay", rc.url}, {"challenge", challenge}},
+		Content:   "",
+	}
+	eventStr, err := SerializeEventForID(*event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize AUTH event: %w", err)
+	}
+	event.ID = ComputeEventID(eventStr)
+
+	if err := authSigner.SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("%w: %v", ErrSignFailed, err)
+	}
+
+	msg, err := json.Marshal([]interface{}{"AUTH", event})
+	if err != nil {
+		return fmt.Errorf("failed to serialize AUTH message: %w", err)
+	}
+	traceFrame(">", rc.url, msg)
+	if err := rc.ws.WriteMessage(websocket.TextMessage, msg); err != nil {
+		return fmt.Errorf("failed to send AUTH message: %w", err)
+	}
+
+	log.Printf("Authenticated to relay %s", rc.url)
+	rc.authenticated = true
+	return nil
+}
@@ -0,0 +1,107 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"log"
+	"time"
+)
+
+// CircuitBreaker controls when publishing skips a relay that keeps failing
+type CircuitBreaker struct {
+	// Failures is the number of consecutive failed attempts that opens the circuit. Zero disables the breaker.
+	Failures int
+	// Cooldown is how long the relay is skipped the first time the circuit opens. It doubles every
+	// time the probe after a cooldown fails, up to MaxCooldown.
+	Cooldown    time.Duration
+	MaxCooldown time.Duration
+}
+
+// breaker is the circuit breaker used by PublishEvent, set with SetCircuitBreaker
+var breaker CircuitBreaker
+
+// SetCircuitBreaker sets when PublishEvent stops attempting a failing relay. Only network errors
+// and timeouts count as failures, a relay rejecting an event is still reachable. It must be called
+// before publishing starts.
+func SetCircuitBreaker(cb CircuitBreaker) {
+	breaker = cb
+}
+
+// Values of RelayStatus.Breaker
+const (
+	// BreakerClosed means the relay is attempted normally
+	BreakerClosed = "closed"
+	// BreakerOpen means the relay is skipped until the cooldown ends
+	BreakerOpen = "open"
+	// BreakerHalfOpen means the cooldown ended and the next attempt probes the relay
+	BreakerHalfOpen = "half-open"
+)
+
+// allowAttempt reports whether the relay may be attempted. After the cooldown a single attempt is
+// let through as a probe, other attempts are skipped until it has answered.
+func allowAttempt(relayURL string) bool {
+	if breaker.Failures <= 0 {
+		return true
+	}
+
+	relayHealthMu.Lock()
+	defer relayHealthMu.Unlock()
+
+	status, ok := relayHealth[relayURL]
+	if !ok || status.BreakerOpenUntil.IsZero() {
+		return true
+	}
+	if status.probing || time.Now().Before(status.BreakerOpenUntil) {
+		return false
+	}
+	status.probing = true
+	log.Printf("Probing %s after its circuit breaker cooldown", relayURL)
+	return true
+}
+
+// updateBreaker opens, closes or extends the relay's circuit after an attempt. It must be called
+// with relayHealthMu held.
+func updateBreaker(status *RelayStatus, err error) {
+	switch {
+	case errors.Is(err, context.Canceled):
+		// Giving up on the event says nothing about the relay, so a cut short probe is repeated
+		status.probing = false
+		return
+	case err == nil || errors.Is(err, ErrRelayRejected):
+		// A relay that answered is reachable, even if it rejected the event
+		if !status.BreakerOpenUntil.IsZero() {
+			log.Printf("Circuit breaker of %s closed, the relay is reachable again", status.URL)
+		}
+		status.ConsecutiveFailures = 0
+		status.BreakerOpenUntil = time.Time{}
+		status.probing = false
+		status.trips = 0
+		return
+	}
+
+	status.ConsecutiveFailures++
+	if breaker.Failures <= 0 || (!status.probing && status.ConsecutiveFailures < breaker.Failures) {
+		return
+	}
+
+	cooldown := breaker.Cooldown << status.trips
+	if breaker.MaxCooldown > 0 && (cooldown > breaker.MaxCooldown || cooldown <= 0) {
+		cooldown = breaker.MaxCooldown
+	}
+	status.trips++
+	status.probing = false
+	status.BreakerOpenUntil = time.Now().Add(cooldown)
+	log.Printf("Circuit breaker of %s opened after %d consecutive failures, skipping it for %s", status.URL, status.ConsecutiveFailures, cooldown)
+}
+
+// breakerState returns the breaker state of the relay for RelayStatus.Breaker
+func breakerState(status RelayStatus) string {
+	switch {
+	case breaker.Failures <= 0 || status.BreakerOpenUntil.IsZero():
+		return BreakerClosed
+	case status.probing || !time.Now().Before(status.BreakerOpenUntil):
+		return BreakerHalfOpen
+	default:
+		return BreakerOpen
+	}
+}
@@ -0,0 +1,299 @@
+package nostr
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// Mention patterns stripped from Discord messages, compiled once and reused
+var (
+	// Channel mentions (e.g., <#1067205302946111602>)
+	channelMentionRe = regexp.MustCompile(`<#[0-9]+>`)
+	// User mentions (e.g., <@UserID> or <@!UserID>)
+	userMentionRe = regexp.MustCompile(`<@!?[0-9]+>`)
+	// Role mentions (e.g., <@&RoleID>)
+	roleMentionRe = regexp.MustCompile(`<@&[0-9]+>`)
+	// Fenced code blocks with an optional language (e.g., ```go\ncode```)
+	codeBlockRe = regexp.MustCompile("(?s)```([A-Za-z0-9_+-]*\n)?(.*?)```")
+)
+
+// ContentOptions controls optional transformations applied by PrepareMessageContent
+type ContentOptions struct {
+	// StripInvisible removes zero-width and other invisible code points before signing
+	StripInvisible bool
+	// Prefix and Suffix are added around the message text, separated by a space.
+	// Attachment URLs still come last.
+	Prefix string
+	Suffix string
+	// StripCodeFences removes the ``` fences around code blocks, keeping the code
+	StripCodeFences bool
+	// IndentCodeBlocks replaces the fences around code blocks with a four space indent
+	IndentCodeBlocks bool
+	// SkipAttachments leaves out the attachment URLs, bridging only the text
+	SkipAttachments bool
+	// UnwrapURLs follows one redirect of the first links in the text and strips tracking parameters
+	// from every link. The lookups take at most UnwrapTimeout together.
+	UnwrapURLs    bool
+	UnwrapTimeout time.Duration
+	// AttachmentPrefix is put before each attachment URL, e.g. "🖼️ ", so they stand out in text-only clients
+	AttachmentPrefix string
+	// MaxAttachments limits the attachment URLs added to the note, the others are replaced by a
+	// count and a link to the Discord message. Zero adds all of them.
+	MaxAttachments int
+	// BlockedDomains leaves out attachment URLs on these domains and their subdomains
+	BlockedDomains []string
+	// MentionName returns the text replacing a user, role or channel mention, such as "@name".
+	// Mentions are removed when it is nil or returns "".
+	MentionName func(mention string) string
+	// AttachmentSeparator is put before each attachment URL, a newline when empty
+	AttachmentSeparator string
+}
+
+// PrepareMessageContent prepares the message content by removing or resolving all mentions and appending attachment URLs
+func PrepareMessageContent(m *discordgo.MessageCreate, opts ContentOptions) string {
+	content := m.Content
+
+	// Some clients leave the @silent command in the text of silent messages
+	if m.Flags&discordgo.MessageFlagsSuppressNotifications != 0 {
+		content = strings.TrimPrefix(content, "@silent ")
+	}
+
+	if opts.StripInvisible {
+		sanitized := stripInvisible(content)
+		recordModification("sanitize", content, sanitized)
+		content = sanitized
+	}
+
+	// Remove channel, user and role mentions, or replace them with their names
+	stripped := replaceMentions(content, channelMentionRe, opts.MentionName)
+	stripped = replaceMentions(stripped, userMentionRe, opts.MentionName)
+	stripped = replaceMentions(stripped, roleMentionRe, opts.MentionName)
+	recordModification("mentions", content, stripped)
+	content = stripped
+
+	if opts.StripCodeFences || opts.IndentCodeBlocks {
+		converted := convertCodeBlocks(content, opts.IndentCodeBlocks)
+		recordModification("code_blocks", content, converted)
+		content = converted
+	}
+
+	if opts.UnwrapURLs {
+		unwrapped := unwrapURLs(content, opts.UnwrapTimeout)
+		recordModification("urls", content, unwrapped)
+		content = unwrapped
+	}
+
+	if opts.Prefix != "" {
+		content = opts.Prefix + " " + content
+	}
+	if opts.Suffix != "" {
+		content += " " + opts.Suffix
+	}
+
+	separator := opts.AttachmentSeparator
+	if separator == "" {
+		separator = "\n"
+	}
+	if opts.SkipAttachments && len(m.Attachments) > 0 {
+		log.Printf("Leaving out %d attachments of message %s", len(m.Attachments), m.ID)
+	} else {
+		var urls []string
+		for _, attachment := range m.Attachments {
+			decodedURL := strings.ReplaceAll(attachment.URL, "\\u0026", "&")
+			if OnBlockedDomain(decodedURL, opts.BlockedDomains) {
+				log.Printf("Leaving out attachment %s of message %s on a blocked domain", attachment.Filename, m.ID)
+				continue
+			}
+			urls = append(urls, decodedURL)
+		}
+
+		// Attachments beyond the limit are summarized with a link to the Discord message
+		var more int
+		if opts.MaxAttachments > 0 && len(urls) > opts.MaxAttachments {
+			more = len(urls) - opts.MaxAttachments
+			urls = urls[:opts.MaxAttachments]
+		}
+		for _, u := range urls {
+			content += separator + opts.AttachmentPrefix + u
+		}
+		if more > 0 {
+			content += "\n" + moreAttachments(m, more)
+		}
+	}
+
+	log.Printf("Message content prepared: %s", content)
+	return content
+}
+
+// moreAttachments returns the note line for attachments left out over the limit, linking the message
+func moreAttachments(m *discordgo.MessageCreate, count int) string {
+	guildID := m.GuildID
+	if guildID == "" {
+		guildID = "@me"
+	}
+	noun := "attachments"
+	if count == 1 {
+		noun = "attachment"
+	}
+	return fmt.Sprintf("(%d more %s: https://discord.com/channels/%s/%s/%s)", count, noun, guildID, m.ChannelID, m.ID)
+}
+
+// OnBlockedDomain reports whether the URL's host is one of the domains or a subdomain of one.
+// The domains must be lowercase.
+func OnBlockedDomain(rawURL string, domains []string) bool {
+	if len(domains) == 0 {
+		return false
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// convertCodeBlocks removes the fences of every code block. With indent, multi-line blocks are
+// indented by four spaces on lines of their own so markdown renders them as code.
+func convertCodeBlocks(content string, indent bool) string {
+	out := ""
+	last := 0
+	for _, match := range codeBlockRe.FindAllStringSubmatchIndex(content, -1) {
+		out += content[last:match[0]]
+		block := content[match[0]:match[1]]
+		code := strings.Trim(content[match[4]:match[5]], "\n")
+		last = match[1]
+
+		if !indent || !strings.Contains(block, "\n") {
+			out += code
+			continue
+		}
+
+		// An indented block must follow a blank line and end its own line
+		if out = strings.TrimRight(out, " \n"); out != "" {
+			out += "\n\n"
+		}
+		out += "    " + strings.ReplaceAll(code, "\n", "\n    ")
+		if last < len(content) && content[last] != '\n' {
+			out += "\n"
+		}
+	}
+	return out + content[last:]
+}
+
+// SplitContent splits the content into parts of at most limit characters, preferring to break at a
+// newline and then at a space. Content within the limit is returned as a single part.
+func SplitContent(content string, limit int) []string {
+	runes := []rune(content)
+	var parts []string
+	for len(runes) > limit {
+		// Keep the full window when the text breaks right after it
+		cut := limit
+		if runes[limit] != '\n' && runes[limit] != ' ' {
+			if i := lastIndexRune(runes[:limit], '\n'); i > 0 {
+				cut = i
+			} else if i := lastIndexRune(runes[:limit], ' '); i > 0 {
+				cut = i
+			}
+		}
+
+		parts = append(parts, strings.TrimRight(string(runes[:cut]), " \n"))
+		runes = []rune(strings.TrimLeft(string(runes[cut:]), " \n"))
+	}
+	if len(runes) > 0 || len(parts) == 0 {
+		parts = append(parts, string(runes))
+	}
+	return parts
+}
+
+// lastIndexRune returns the index of the last occurrence of r in runes, or -1
+func lastIndexRune(runes []rune, r rune) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if runes[i] == r {
+			return i
+		}
+	}
+	return -1
+}
+
+// stripInvisible removes zero-width, bidi control and other invisible code points from the content.
+// The zero-width joiner is kept since it is needed for composed emoji.
+func stripInvisible(content string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t' || r == '\u200d':
+			return r
+		case unicode.IsControl(r),
+			r == '\u00ad',                  // Soft hyphen
+			r == '\u180e',                  // Mongolian vowel separator
+			r >= '\u200b' && r <= '\u200f', // Zero-width space, non-joiner and direction marks
+			r >= '\u202a' && r <= '\u202e', // Bidi embeddings and overrides
+			r >= '\u2060' && r <= '\u2064', // Word joiner and invisible operators
+			r >= '\u2066' && r <= '\u2069', // Bidi isolates
+			r == '\ufeff':                  // Zero-width no-break space
+			return -1
+		}
+		return r
+	}, content)
+}
+
+// replaceMentions replaces all matches of the given regex in the content with their names, removing
+// them when there is no name
+func replaceMentions(content string, re *regexp.Regexp, name func(mention string) string) string {
+	if name == nil {
+		return re.ReplaceAllString(content, "")
+	}
+	return re.ReplaceAllStringFunc(content, name)
+}
+
+// ContentModification counts how often a preparation step changed message content
+type ContentModification struct {
+	Messages     int // Messages the step changed
+	BytesRemoved int // Total bytes the step removed from those messages
+}
+
+var (
+	contentStatsMu sync.Mutex
+	contentStats   = make(map[string]ContentModification)
+)
+
+// ContentModifications returns a snapshot of how often each preparation step changed content
+func ContentModifications() map[string]ContentModification {
+	contentStatsMu.Lock()
+	defer contentStatsMu.Unlock()
+
+	snapshot := make(map[string]ContentModification, len(contentStats))
+	for step, stats := range contentStats {
+		snapshot[step] = stats
+	}
+	return snapshot
+}
+
+// recordModification logs and counts a change made to the content by the named preparation step
+func recordModification(step, before, after string) {
+	if before == after {
+		return
+	}
+
+	removed := len(before) - len(after)
+	contentStatsMu.Lock()
+	stats := contentStats[step]
+	stats.Messages++
+	stats.BytesRemoved += removed
+	contentStats[step] = stats
+	contentStatsMu.Unlock()
+
+	log.Printf("Content modified by %s step: %d bytes removed", step, removed)
+}
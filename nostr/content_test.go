@@ -0,0 +1,197 @@
+package nostr
+
+import (
+	"io"
+	"log"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/bwmarrin/discordgo"
+)
+
+// quietLog discards the log output of the test, which prepares content a great many times
+func quietLog(t testing.TB) {
+	out := log.Writer()
+	log.SetOutput(io.Discard)
+	t.Cleanup(func() { log.SetOutput(out) })
+}
+
+func FuzzPrepareMessageContent(f *testing.F) {
+	seeds := []string{
+		"<@123> hello <#456> and <@&789>",
+		"<<@1>@2>>",
+		"<@<@!12>34> <#<#5>6>",
+		"<@&<@&1>>",
+		"<@!> <#> <@&>",
+		"```go\nfmt.Println(\"<@1>\")\n```",
+		"text ```inline``` more",
+		"``````",
+		"```\n```\n```",
+		"before\n```py\nprint(1)\n```after",
+		"```unterminated\ncode",
+		"e\u0301\u200d\u202e\ufeff \U0001F469\u200d\U0001F469\u200d\U0001F467 \U0001F1E9\U0001F1EA \u2028",
+		"   \x00 \t\r\n",
+		strings.Repeat("word ", 100),
+		strings.Repeat("x", 500),
+		"@silent <@1> ```a\nb``` " + strings.Repeat("🍕", 80),
+	}
+	for i, seed := range seeds {
+		f.Add(seed, uint8(i%3), uint16(i*37))
+	}
+
+	f.Fuzz(func(t *testing.T, content string, mode uint8, limit uint16) {
+		quietLog(t)
+		opts := ContentOptions{
+			StripInvisible:   mode&4 != 0,
+			StripCodeFences:  mode%3 == 1,
+			IndentCodeBlocks: mode%3 == 2,
+		}
+		prepared := PrepareMessageContent(&discordgo.MessageCreate{Message: &discordgo.Message{Content: content}}, opts)
+		if utf8.ValidString(content) && !utf8.ValidString(prepared) {
+			t.Fatalf("PrepareMessageContent(%q) = %q, which is not valid UTF-8", content, prepared)
+		}
+
+		splitLimit := int(limit)%300 + 1
+		parts := SplitContent(prepared, splitLimit)
+		if len(parts) == 0 {
+			t.Fatalf("SplitContent(%q, %d) returned no parts", prepared, splitLimit)
+		}
+		for _, part := range parts {
+			if n := utf8.RuneCountInString(part); n > splitLimit {
+				t.Fatalf("SplitContent(%q, %d) returned a part of %d characters", prepared, splitLimit, n)
+			}
+		}
+		// Only the spaces and newlines where the content is cut may be dropped. Invalid UTF-8, which
+		// Discord never sends, is replaced when the content is split.
+		if !utf8.ValidString(prepared) {
+			return
+		}
+		squeeze := strings.NewReplacer(" ", "", "\n", "")
+		if joined := squeeze.Replace(strings.Join(parts, "")); joined != squeeze.Replace(prepared) {
+			t.Fatalf("SplitContent(%q, %d) = %q, which lost text", prepared, splitLimit, parts)
+		}
+	})
+}
+
+func TestPrepareMessageContentAttachments(t *testing.T) {
+	const (
+		image = "https://cdn.discordapp.com/attachments/1/2/image.png"
+		video = "https://cdn.discordapp.com/attachments/1/3/video.mp4"
+		// Discord signs attachment URLs with query parameters, all joined by &
+		signed = "https://cdn.discordapp.com/attachments/1/4/file.txt?ex=65&is=66&hm=ab"
+	)
+	attachments := func(urls ...string) []*discordgo.MessageAttachment {
+		var list []*discordgo.MessageAttachment
+		for _, u := range urls {
+			list = append(list, &discordgo.MessageAttachment{URL: u, Filename: u[strings.LastIndex(u, "/")+1:]})
+		}
+		return list
+	}
+
+	tests := []struct {
+		name        string
+		content     string
+		attachments []*discordgo.MessageAttachment
+		opts        ContentOptions
+		want        string
+	}{
+		{
+			name:        "single attachment",
+			attachments: attachments(image),
+			want:        "\n" + image,
+		},
+		{
+			name:        "multiple attachments",
+			attachments: attachments(image, video),
+			want:        "\n" + image + "\n" + video,
+		},
+		{
+			name:        "ampersand in the URL",
+			attachments: attachments(signed),
+			want:        "\n" + signed,
+		},
+		{
+			name:        "escaped ampersand in the URL",
+			attachments: attachments(strings.ReplaceAll(signed, "&", "\\u0026")),
+			want:        "\n" + signed,
+		},
+		{
+			name:        "content and attachment",
+			content:     "look at this",
+			attachments: attachments(image),
+			want:        "look at this\n" + image,
+		},
+		{
+			name:        "separator",
+			content:     "look at this",
+			attachments: attachments(image, video),
+			opts:        ContentOptions{AttachmentSeparator: "\n\n"},
+			want:        "look at this\n\n" + image + "\n\n" + video,
+		},
+		{
+			name:        "attachment prefix",
+			content:     "look at this",
+			attachments: attachments(image, video),
+			opts:        ContentOptions{AttachmentPrefix: "🖼️ "},
+			want:        "look at this\n🖼️ " + image + "\n🖼️ " + video,
+		},
+		{
+			name:        "prefix and suffix stay before the attachments",
+			content:     "look at this",
+			attachments: attachments(image),
+			opts:        ContentOptions{Prefix: "[discord]", Suffix: "#bridged"},
+			want:        "[discord] look at this #bridged\n" + image,
+		},
+		{
+			name:        "max attachments",
+			content:     "look at this",
+			attachments: attachments(image, video, signed),
+			opts:        ContentOptions{MaxAttachments: 1},
+			want:        "look at this\n" + image + "\n(2 more attachments: https://discord.com/channels/10/20/30)",
+		},
+		{
+			name:        "max attachments not reached",
+			content:     "look at this",
+			attachments: attachments(image, video),
+			opts:        ContentOptions{MaxAttachments: 2},
+			want:        "look at this\n" + image + "\n" + video,
+		},
+		{
+			name:        "blocked domain",
+			content:     "look at this",
+			attachments: attachments("https://media.blocked.example/a.png", image, "https://blocked.example/b.png"),
+			opts:        ContentOptions{BlockedDomains: []string{"blocked.example"}},
+			want:        "look at this\n" + image,
+		},
+		{
+			name:        "blocked attachments don't count towards the limit",
+			content:     "look at this",
+			attachments: attachments("https://blocked.example/a.png", image, video),
+			opts:        ContentOptions{BlockedDomains: []string{"blocked.example"}, MaxAttachments: 1},
+			want:        "look at this\n" + image + "\n(1 more attachment: https://discord.com/channels/10/20/30)",
+		},
+		{
+			name:        "skipped attachments",
+			content:     "look at this",
+			attachments: attachments(image),
+			opts:        ContentOptions{SkipAttachments: true},
+			want:        "look at this",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &discordgo.MessageCreate{Message: &discordgo.Message{
+				ID:          "30",
+				ChannelID:   "20",
+				GuildID:     "10",
+				Content:     tt.content,
+				Attachments: tt.attachments,
+			}}
+			if got := PrepareMessageContent(m, tt.opts); got != tt.want {
+				t.Errorf("PrepareMessageContent() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
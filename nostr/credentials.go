@@ -0,0 +1,35 @@
+package nostr
+
+import (
+	"encoding/base64"
+	"net/http"
+)
+
+// BasicAuth holds HTTP basic auth credentials for a relay behind an authenticating proxy
+type BasicAuth struct {
+	Username string
+	Password string
+}
+
+// relayCredentials holds the basic auth credentials per relay URL
+var relayCredentials = make(map[string]BasicAuth)
+
+// SetRelayCredentials sets basic auth credentials sent with the WebSocket upgrade and NIP-11 requests
+// of individual relays. It must be called before any relay is contacted.
+func SetRelayCredentials(credentials map[string]BasicAuth) {
+	relayCredentials = make(map[string]BasicAuth, len(credentials))
+	for relayURL, auth := range credentials {
+		relayCredentials[relayURL] = auth
+	}
+}
+
+// relayHeader returns the request headers for connecting to the relay, nil when none are needed
+func relayHeader(relayURL string) http.Header {
+	auth, ok := relayCredentials[relayURL]
+	if !ok {
+		return nil
+	}
+
+	token := base64.StdEncoding.EncodeToString([]byte(auth.Username + ":" + auth.Password))
+	return http.Header{"Authorization": {"Basic " + token}}
+}
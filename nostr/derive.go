@@ -0,0 +1,36 @@
+package nostr
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// authorKeyLabel prefixes the Discord user ID in the message authenticated by HMAC to derive a key
+const authorKeyLabel = "ndmBridge author key "
+
+// DeriveAuthorKey derives the hex private key of a Discord user from the 32-byte hex master seed.
+// The key is HMAC-SHA256(seed, "ndmBridge author key " + userID) read as a big-endian number modulo
+// the secp256k1 group order, so the same seed and user ID always give the same key and knowing one
+// user's key reveals nothing about the seed or other users' keys.
+func DeriveAuthorKey(seedHex, userID string) (string, error) {
+	seed, err := hex.DecodeString(seedHex)
+	if err != nil || len(seed) != 32 {
+		return "", fmt.Errorf("failed to decode seed: expected 32 bytes of hex")
+	}
+
+	mac := hmac.New(sha256.New, seed)
+	mac.Write([]byte(authorKeyLabel + userID))
+
+	var scalar btcec.ModNScalar
+	scalar.SetByteSlice(mac.Sum(nil))
+	if scalar.IsZero() {
+		return "", errors.New("derived key is zero")
+	}
+	privKey := btcec.PrivKeyFromScalar(&scalar)
+	return hex.EncodeToString(privKey.Serialize()), nil
+}
@@ -0,0 +1,58 @@
+package nostr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+)
+
+// Errors returned by the nostr package, usable with errors.Is
+var (
+	// ErrDialFailed means the relay could not be reached
+	ErrDialFailed = errors.New("failed to connect to relay")
+	// ErrRelayRejected means the relay answered with an OK message marking the event as rejected
+	ErrRelayRejected = errors.New("relay rejected event")
+	// ErrSignFailed means the event could not be signed with the configured key
+	ErrSignFailed = errors.New("failed to sign event")
+	// ErrRelayTimeout means the relay did not respond in time
+	ErrRelayTimeout = errors.New("relay did not respond in time")
+	// ErrOverRelayLimit means the event exceeds a limit the relay advertises in its NIP-11 document
+	ErrOverRelayLimit = errors.New("event exceeds relay limits")
+	// ErrCircuitOpen means the relay was skipped because its circuit breaker is open after repeated failures
+	ErrCircuitOpen = errors.New("relay skipped after repeated failures")
+	// ErrEventTooLarge means the event exceeds the size limits of every relay, so it wasn't sent anywhere
+	ErrEventTooLarge = errors.New("event is too large for every relay")
+)
+
+// RelayError describes a failure to publish an event to a specific relay
+type RelayError struct {
+	Relay  string
+	Reason string // Reason given by the relay when it rejected the event
+	Err    error
+}
+
+func (e *RelayError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("%s: %v: %s", e.Relay, e.Err, e.Reason)
+	}
+	return fmt.Sprintf("%s: %v", e.Relay, e.Err)
+}
+
+func (e *RelayError) Unwrap() error {
+	return e.Err
+}
+
+// isTimeout reports whether err was caused by a network deadline expiring
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// contextError maps an expired context deadline to ErrRelayTimeout and passes other errors through
+func contextError(err error) error {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w: %v", ErrRelayTimeout, err)
+	}
+	return err
+}
@@ -0,0 +1,89 @@
+package nostr
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// RelayStatus describes the recent publishing health of a relay
+type RelayStatus struct {
+	URL         string    `json:"url"`
+	Connected   bool      `json:"connected"`
+	Published   int       `json:"published"`
+	Failed      int       `json:"failed"`
+	LastSuccess time.Time `json:"last_success,omitempty"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastErrorAt time.Time `json:"last_error_at,omitempty"`
+	// ConsecutiveFailures counts the network errors and timeouts since the last attempt that reached the relay
+	ConsecutiveFailures int `json:"consecutive_failures"`
+	// Breaker is the circuit breaker state, BreakerClosed, BreakerOpen or BreakerHalfOpen
+	Breaker          string    `json:"breaker"`
+	BreakerOpenUntil time.Time `json:"breaker_open_until,omitempty"`
+
+	probing bool // A probe attempt is in flight after the cooldown
+	trips   int  // Times the circuit opened in a row, doubling the cooldown each time
+}
+
+var (
+	relayHealthMu sync.Mutex
+	relayHealth   = make(map[string]*RelayStatus)
+)
+
+// recordPublish updates the health of the relay after publishing an event to it
+func recordPublish(relayURL string, err error) {
+	relayHealthMu.Lock()
+	defer relayHealthMu.Unlock()
+
+	status, ok := relayHealth[relayURL]
+	if !ok {
+		status = &RelayStatus{URL: relayURL}
+		relayHealth[relayURL] = status
+	}
+	updateBreaker(status, err)
+
+	if err != nil {
+		status.Failed++
+		status.LastError = err.Error()
+		status.LastErrorAt = time.Now()
+		return
+	}
+	status.Published++
+	status.LastSuccess = time.Now()
+}
+
+// RelayStatuses returns the health of every relay published to so far, sorted by URL
+func RelayStatuses() []RelayStatus {
+	relayHealthMu.Lock()
+	statuses := make([]RelayStatus, 0, len(relayHealth))
+	for _, status := range relayHealth {
+		statuses = append(statuses, *status)
+	}
+	relayHealthMu.Unlock()
+
+	for i := range statuses {
+		statuses[i].Connected = isConnected(statuses[i].URL)
+		statuses[i].Breaker = breakerState(statuses[i])
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].URL < statuses[j].URL })
+	return statuses
+}
+
+// isConnected reports whether a persistent connection to the relay is currently open
+func isConnected(relayURL string) bool {
+	relayConnsMu.Lock()
+	rc, ok := relayConns[relayURL]
+	relayConnsMu.Unlock()
+	if !ok {
+		return false
+	}
+
+	select {
+	case rc.lock <- struct{}{}:
+		defer rc.release()
+		return !rc.isClosed()
+	default:
+		// A publish holds the connection, so it is in use
+		return true
+	}
+}
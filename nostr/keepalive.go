@@ -0,0 +1,53 @@
+package nostr
+
+import (
+	"log"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// pingWriteTimeout bounds how long sending a single ping may take
+const pingWriteTimeout = 10 * time.Second
+
+// pingInterval is how often relay connections are pinged, zero disables keepalive pings
+var pingInterval time.Duration
+
+// SetPingInterval sets how often relay connections are sent WebSocket pings so idle sockets aren't
+// dropped. It must be called before connecting; zero or less disables pings.
+func SetPingInterval(d time.Duration) {
+	pingInterval = max(d, 0)
+}
+
+// startKeepalive pings the connection every ping interval until done is closed. Each pong extends
+// the read deadline past the next ping, so when a pong goes missing the pending read fails and the
+// connection counts as dead, making the next publish or subscription reconnect.
+func startKeepalive(ws *websocket.Conn, relayURL string, done <-chan struct{}) {
+	if pingInterval <= 0 {
+		return
+	}
+
+	deadline := 2 * pingInterval
+	ws.SetReadDeadline(time.Now().Add(deadline))
+	ws.SetPongHandler(func(string) error {
+		return ws.SetReadDeadline(time.Now().Add(deadline))
+	})
+
+	go func() {
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(pingWriteTimeout)); err != nil {
+					log.Printf("Error pinging relay %s: %v", relayURL, err)
+					ws.Close()
+					return
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+}
@@ -0,0 +1,56 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// KeyRing signs events with the local key of the event's pubkey, falling back to another signer for
+// pubkeys it holds no key for
+type KeyRing struct {
+	fallback Signer
+
+	mu   sync.RWMutex
+	keys map[string]*KeySigner
+}
+
+// NewKeyRing creates a key ring from hex encoded private keys that signs events of other pubkeys
+// with fallback
+func NewKeyRing(fallback Signer, privKeysHex []string) (*KeyRing, error) {
+	ring := &KeyRing{fallback: fallback, keys: make(map[string]*KeySigner, len(privKeysHex))}
+	for _, privKeyHex := range privKeysHex {
+		if _, err := ring.Add(privKeyHex); err != nil {
+			return nil, err
+		}
+	}
+	return ring, nil
+}
+
+// Add adds the hex encoded private key to the ring and returns its pubkey
+func (k *KeyRing) Add(privKeyHex string) (string, error) {
+	signer, err := NewKeySigner(privKeyHex)
+	if err != nil {
+		return "", err
+	}
+	pubkey := signer.PublicKey()
+
+	k.mu.Lock()
+	k.keys[pubkey] = signer
+	k.mu.Unlock()
+	return pubkey, nil
+}
+
+// SignEvent signs the event with the key of its pubkey, or with the fallback signer
+func (k *KeyRing) SignEvent(ctx context.Context, event *NostrEvent) error {
+	k.mu.RLock()
+	signer, ok := k.keys[event.Pubkey]
+	k.mu.RUnlock()
+	if ok {
+		return signer.SignEvent(ctx, event)
+	}
+	if k.fallback == nil {
+		return fmt.Errorf("no key for pubkey %s", event.Pubkey)
+	}
+	return k.fallback.SignEvent(ctx, event)
+}
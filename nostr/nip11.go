@@ -0,0 +1,126 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+// RelayInfo is the part of a NIP-11 relay information document the bridge uses
+type RelayInfo struct {
+	Name       string          `json:"name"`
+	Limitation RelayLimitation `json:"limitation"`
+}
+
+// RelayLimitation holds the limits a relay advertises in its NIP-11 document
+type RelayLimitation struct {
+	MaxMessageLength int  `json:"max_message_length"`
+	MaxContentLength int  `json:"max_content_length"`
+	MinPowDifficulty int  `json:"min_pow_difficulty"`
+	AuthRequired     bool `json:"auth_required"`
+	PaymentRequired  bool `json:"payment_required"`
+}
+
+// FetchRelayInfo requests the NIP-11 information document of the relay
+func FetchRelayInfo(ctx context.Context, relayURL string) (*RelayInfo, error) {
+	httpURL := relayURL
+	switch {
+	case strings.HasPrefix(relayURL, "wss://"):
+		httpURL = "https://" + strings.TrimPrefix(relayURL, "wss://")
+	case strings.HasPrefix(relayURL, "ws://"):
+		httpURL = "http://" + strings.TrimPrefix(relayURL, "ws://")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, httpURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create relay information request: %w", err)
+	}
+	for name, values := range relayHeader(relayURL) {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/nostr+json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch relay information: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch relay information: %s", resp.Status)
+	}
+
+	var info RelayInfo
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return nil, fmt.Errorf("failed to decode relay information: %w", err)
+	}
+	return &info, nil
+}
+
+var (
+	// relayLimits holds the advertised limits per relay URL, filled by LoadRelayLimits
+	relayLimits = make(map[string]RelayLimitation)
+	// skipOverLimit makes PublishEvent skip relays whose limits an event exceeds instead of only warning
+	skipOverLimit bool
+)
+
+// LoadRelayLimits fetches the NIP-11 document of every relay and remembers its limits. Relays whose
+// document can't be fetched are treated as unlimited. Events are mined to the highest proof of work
+// difficulty the relays require. When skip is set, PublishEvent doesn't send events to relays whose
+// size limits they exceed; otherwise it only logs a warning.
+// It must be called after SetAuth and before publishing starts.
+func LoadRelayLimits(ctx context.Context, relayURLs []string, skip bool) {
+	skipOverLimit = skip
+	for _, relayURL := range relayURLs {
+		info, err := FetchRelayInfo(ctx, relayURL)
+		if err != nil {
+			log.Printf("Error fetching relay information for %s: %v", relayURL, err)
+			continue
+		}
+		relayLimits[relayURL] = info.Limitation
+		log.Printf("Relay %s limits: %+v", relayURL, info.Limitation)
+
+		limits := info.Limitation
+		if limits.AuthRequired && !authRelays[relayURL] {
+			log.Printf("Relay %s requires authentication but isn't trusted for AUTH, events will likely be rejected", relayURL)
+		}
+		if limits.PaymentRequired {
+			log.Printf("Relay %s requires payment, events will be rejected unless the bridge pubkey has paid", relayURL)
+		}
+		if limits.MinPowDifficulty > MaxPoWDifficulty {
+			log.Printf("Relay %s requires proof of work of difficulty %d, more than the %d the bridge mines", relayURL, limits.MinPowDifficulty, MaxPoWDifficulty)
+		}
+	}
+}
+
+// checkRelayLimits returns an error when the event exceeds the limits advertised by the relay
+func checkRelayLimits(relayURL string, event NostrEvent) error {
+	limits, ok := relayLimits[relayURL]
+	if !ok {
+		return nil
+	}
+
+	if n := utf8.RuneCountInString(event.Content); limits.MaxContentLength > 0 && n > limits.MaxContentLength {
+		return &RelayError{
+			Relay:  relayURL,
+			Reason: fmt.Sprintf("content is %d characters, relay allows %d", n, limits.MaxContentLength),
+			Err:    ErrOverRelayLimit,
+		}
+	}
+
+	if limits.MaxMessageLength > 0 {
+		message, err := json.Marshal([]interface{}{"EVENT", event})
+		if err == nil && len(message) > limits.MaxMessageLength {
+			return &RelayError{
+				Relay:  relayURL,
+				Reason: fmt.Sprintf("message is %d bytes, relay allows %d", len(message), limits.MaxMessageLength),
+				Err:    ErrOverRelayLimit,
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// bech32Charset is the alphabet of bech32 data characters
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// EncodeNevent encodes the event ID and relay hints as a NIP-19 nevent1… identifier
+func EncodeNevent(eventID string, relays []string) (string, error) {
+	id, err := hex.DecodeString(eventID)
+	if err != nil || len(id) != 32 {
+		return "", fmt.Errorf("failed to decode event ID: expected 32 bytes of hex")
+	}
+
+	// TLV entries: 0 is the event ID, 1 is a relay URL
+	tlv := append([]byte{0, 32}, id...)
+	for _, relay := range relays {
+		if len(relay) > 255 {
+			return "", fmt.Errorf("relay URL %s is too long for a nevent", relay)
+		}
+		tlv = append(tlv, 1, byte(len(relay)))
+		tlv = append(tlv, relay...)
+	}
+
+	return bech32Encode("nevent", tlv), nil
+}
+
+// EncodeNpub encodes the hex pubkey as a NIP-19 npub1… identifier
+func EncodeNpub(pubkey string) (string, error) {
+	key, err := hex.DecodeString(pubkey)
+	if err != nil || len(key) != 32 {
+		return "", fmt.Errorf("failed to decode pubkey: expected 32 bytes of hex")
+	}
+	return bech32Encode("npub", key), nil
+}
+
+// bech32Encode encodes the bytes as bech32 with the human readable prefix. Unlike BIP-173 it has no
+// length limit, as NIP-19 entities with relay hints are usually longer than 90 characters.
+func bech32Encode(hrp string, data []byte) string {
+	values, _ := convertBits(data, 8, 5, true)
+	checksum := bech32Checksum(hrp, values)
+
+	var sb strings.Builder
+	sb.WriteString(hrp)
+	sb.WriteByte('1')
+	for _, v := range append(values, checksum...) {
+		sb.WriteByte(bech32Charset[v])
+	}
+	return sb.String()
+}
+
+// EventPointer is an event referenced by a NIP-19 note1… or nevent1… identifier
+type EventPointer struct {
+	ID     string
+	Relays []string // Relay hints, only given by nevent
+	Author string   // Hex pubkey of the author, if the nevent names it
+}
+
+// DecodeEventPointer decodes a note1… or nevent1… identifier, with or without the nostr: prefix
+func DecodeEventPointer(ref string) (EventPointer, error) {
+	hrp, data, err := bech32Decode(strings.TrimPrefix(ref, "nostr:"))
+	if err != nil {
+		return EventPointer{}, err
+	}
+
+	switch hrp {
+	case "note":
+		if len(data) != 32 {
+			return EventPointer{}, errors.New("note must hold a 32 byte event ID")
+		}
+		return EventPointer{ID: hex.EncodeToString(data)}, nil
+	case "nevent":
+		var pointer EventPointer
+		for len(data) >= 2 {
+			typ, length := data[0], int(data[1])
+			if len(data) < 2+length {
+				return EventPointer{}, errors.New("nevent TLV entry is truncated")
+			}
+			value := data[2 : 2+length]
+			data = data[2+length:]
+
+			// Unknown TLV types are skipped as NIP-19 requires
+			switch {
+			case typ == 0 && length == 32:
+				pointer.ID = hex.EncodeToString(value)
+			case typ == 1:
+				pointer.Relays = append(pointer.Relays, string(value))
+			case typ == 2 && length == 32:
+				pointer.Author = hex.EncodeToString(value)
+			}
+		}
+		if pointer.ID == "" {
+			return EventPointer{}, errors.New("nevent has no event ID")
+		}
+		return pointer, nil
+	}
+	return EventPointer{}, fmt.Errorf("%s1… is not an event identifier", hrp)
+}
+
+// bech32Decode decodes a lowercase bech32 string, verifying its checksum, into the human readable
+// prefix and the data bytes
+func bech32Decode(s string) (string, []byte, error) {
+	sep := strings.LastIndexByte(s, '1')
+	if sep < 1 || len(s)-sep-1 < 6 {
+		return "", nil, errors.New("invalid bech32 string")
+	}
+	hrp := s[:sep]
+
+	values := make([]byte, 0, len(s)-sep-1)
+	for i := sep + 1; i < len(s); i++ {
+		v := strings.IndexByte(bech32Charset, s[i])
+		if v < 0 {
+			return "", nil, fmt.Errorf("invalid bech32 character %q", s[i])
+		}
+		values = append(values, byte(v))
+	}
+
+	data, checksum := values[:len(values)-6], values[len(values)-6:]
+	if string(bech32Checksum(hrp, data)) != string(checksum) {
+		return "", nil, errors.New("invalid bech32 checksum")
+	}
+	decoded, ok := convertBits(data, 5, 8, false)
+	if !ok {
+		return "", nil, errors.New("invalid bech32 padding")
+	}
+	return hrp, decoded, nil
+}
+
+// convertBits regroups the bits of data from groups of from bits into groups of to bits. With pad the
+// end is padded with zero bits, otherwise leftover bits must be zero padding and are dropped.
+func convertBits(data []byte, from, to uint, pad bool) ([]byte, bool) {
+	var acc, bits uint
+	maxValue := uint(1)<<to - 1
+	var out []byte
+	for _, b := range data {
+		acc = acc<<from | uint(b)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxValue))
+		}
+	}
+	if pad && bits > 0 {
+		out = append(out, byte(acc<<(to-bits)&maxValue))
+	} else if !pad && (bits >= from || acc<<(to-bits)&maxValue != 0) {
+		return nil, false
+	}
+	return out, true
+}
+
+// bech32Checksum computes the six checksum values for the prefix and 5-bit data values
+func bech32Checksum(hrp string, values []byte) []byte {
+	var expanded []byte
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	expanded = append(expanded, values...)
+	expanded = append(expanded, 0, 0, 0, 0, 0, 0)
+
+	polymod := bech32Polymod(expanded) ^ 1
+	checksum := make([]byte, 6)
+	for i := range checksum {
+		checksum[i] = byte(polymod >> (5 * (5 - i)) & 31)
+	}
+	return checksum
+}
+
+// bech32Polymod is the BCH checksum function of BIP-173
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i, g := range generator {
+			if top>>i&1 == 1 {
+				chk ^= g
+			}
+		}
+	}
+	return chk
+}
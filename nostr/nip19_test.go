@@ -0,0 +1,83 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"slices"
+	"testing"
+)
+
+const (
+	testPubkey  = "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d"
+	testEventID = "b9f5441e45ca39179320e0031cfb18e34078673dcc3d3e3a3b3a981760aa5696"
+)
+
+func TestEncodeNpub(t *testing.T) {
+	// Example from NIP-19
+	got, err := EncodeNpub(testPubkey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "npub180cvv07tjdrrgpa0j7j7tmnyl2yr6yr7l8j4s3evf6u64th6gkwsyjh6w6"; got != want {
+		t.Errorf("EncodeNpub() = %s, want %s", got, want)
+	}
+}
+
+func TestBech32DecodeNprofile(t *testing.T) {
+	// Example from NIP-19, decoded into its TLV entries
+	hrp, data, err := bech32Decode("nprofile1qqsrhuxx8l9ex335q7he0f09aej04zpazpl0ne2cgukyawd24mayt8gpp4mhxue69uhhytnc9e3k7mgpz4mhxue69uhkg6nzv9ejuumpv34kytnrdaksjlyr9p")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey, _ := hex.DecodeString(testPubkey)
+	want := append([]byte{0, 32}, pubkey...)
+	want = append(want, 1, 13)
+	want = append(want, "wss://r.x.com"...)
+	want = append(want, 1, 21)
+	want = append(want, "wss://djbas.sadkb.com"...)
+	if hrp != "nprofile" || !slices.Equal(data, want) {
+		t.Errorf("bech32Decode() = %s, %x, want nprofile, %x", hrp, data, want)
+	}
+}
+
+func TestEncodeNevent(t *testing.T) {
+	// NIP-19 has no nevent example, these come from the BIP-173 reference encoder
+	tests := []struct {
+		relays []string
+		want   string
+	}{
+		{nil, "nevent1qqstna2yrezu5wghjvswqqculvvwxsrcvu7uc0f78gan4xqhvz49d9s5p05vw"},
+		{[]string{"wss://nos.lol"}, "nevent1qqstna2yrezu5wghjvswqqculvvwxsrcvu7uc0f78gan4xqhvz49d9spp4mhxue69uhkummn9ekx7mqdkyyq2"},
+	}
+	for _, tt := range tests {
+		got, err := EncodeNevent(testEventID, tt.relays)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("EncodeNevent(%v) = %s, want %s", tt.relays, got, tt.want)
+		}
+	}
+}
+
+func TestEventPointerRoundTrip(t *testing.T) {
+	relays := []string{"wss://nos.lol", "wss://relay.damus.io"}
+	nevent, err := EncodeNevent(testEventID, relays)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ref := range []string{nevent, "nostr:" + nevent} {
+		pointer, err := DecodeEventPointer(ref)
+		if err != nil {
+			t.Fatalf("DecodeEventPointer(%s) = %v", ref, err)
+		}
+		if pointer.ID != testEventID || !slices.Equal(pointer.Relays, relays) {
+			t.Errorf("DecodeEventPointer(%s) = %+v, want ID %s and relays %v", ref, pointer, testEventID, relays)
+		}
+	}
+
+	pointer, err := DecodeEventPointer("note1h865g8j9egu30yequqp3e7ccudq8seeaes7nuw3m82vpwc9226tqtudlvp")
+	if err != nil || pointer.ID != testEventID {
+		t.Errorf("DecodeEventPointer(note) = %+v, %v, want ID %s", pointer, err, testEventID)
+	}
+}
@@ -0,0 +1,160 @@
+package nostr
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math/bits"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"golang.org/x/crypto/chacha20"
+	"golang.org/x/crypto/hkdf"
+)
+
+// nip44Version is the payload version byte of NIP-44 v2 encryption
+const nip44Version = 2
+
+// nip44ConversationKey derives the NIP-44 conversation key shared between privKey and the hex pubkey
+func nip44ConversationKey(privKey *btcec.PrivateKey, pubkeyHex string) ([]byte, error) {
+	pubkeyBytes, err := hex.DecodeString(pubkeyHex)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode pubkey: %w", err)
+	}
+	pubkey, err := schnorr.ParsePubKey(pubkeyBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse pubkey: %w", err)
+	}
+
+	sharedX := btcec.GenerateSharedSecret(privKey, pubkey)
+	return hkdf.Extract(sha256.New, sharedX, []byte("nip44-v2")), nil
+}
+
+// nip44MessageKeys expands the conversation key into the per-message ChaCha20 key, nonce and HMAC key
+func nip44MessageKeys(conversationKey, nonce []byte) (chachaKey, chachaNonce, hmacKey []byte, err error) {
+	keys := make([]byte, 76)
+	if _, err := io.ReadFull(hkdf.Expand(sha256.New, conversationKey, nonce), keys); err != nil {
+		return nil, nil, nil, err
+	}
+	return keys[0:32], keys[32:44], keys[44:76], nil
+}
+
+// nip44Encrypt encrypts plaintext with the conversation key and returns the base64 payload
+func nip44Encrypt(conversationKey []byte, plaintext string) (string, error) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	return nip44EncryptWithNonce(conversationKey, nonce, plaintext)
+}
+
+// nip44EncryptWithNonce is nip44Encrypt with the given 32 byte nonce
+func nip44EncryptWithNonce(conversationKey, nonce []byte, plaintext string) (string, error) {
+	chachaKey, chachaNonce, hmacKey, err := nip44MessageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+
+	padded, err := nip44Pad(plaintext)
+	if err != nil {
+		return "", err
+	}
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(chachaKey, chachaNonce)
+	if err != nil {
+		return "", err
+	}
+	ciphertext := make([]byte, len(padded))
+	cipher.XORKeyStream(ciphertext, padded)
+
+	payload := []byte{nip44Version}
+	payload = append(payload, nonce...)
+	payload = append(payload, ciphertext...)
+	payload = append(payload, nip44MAC(hmacKey, nonce, ciphertext)...)
+
+	return base64.StdEncoding.EncodeToString(payload), nil
+}
+
+// nip44Decrypt verifies and decrypts a base64 payload with the conversation key
+func nip44Decrypt(conversationKey []byte, payload string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(payload)
+	if err != nil {
+		return "", fmt.Errorf("failed to decode payload: %w", err)
+	}
+	if len(data) < 99 || data[0] != nip44Version {
+		return "", errors.New("unsupported or malformed payload")
+	}
+
+	nonce := data[1:33]
+	ciphertext := data[33 : len(data)-32]
+	mac := data[len(data)-32:]
+
+	chachaKey, chachaNonce, hmacKey, err := nip44MessageKeys(conversationKey, nonce)
+	if err != nil {
+		return "", err
+	}
+	if !hmac.Equal(mac, nip44MAC(hmacKey, nonce, ciphertext)) {
+		return "", errors.New("invalid payload MAC")
+	}
+
+	cipher, err := chacha20.NewUnauthenticatedCipher(chachaKey, chachaNonce)
+	if err != nil {
+		return "", err
+	}
+	padded := make([]byte, len(ciphertext))
+	cipher.XORKeyStream(padded, ciphertext)
+
+	return nip44Unpad(padded)
+}
+
+// nip44MAC authenticates the ciphertext with the nonce as associated data
+func nip44MAC(hmacKey, nonce, ciphertext []byte) []byte {
+	h := hmac.New(sha256.New, hmacKey)
+	h.Write(nonce)
+	h.Write(ciphertext)
+	return h.Sum(nil)
+}
+
+// nip44PaddedLen returns the padded length NIP-44 uses for a plaintext of the given length
+func nip44PaddedLen(length int) int {
+	if length <= 32 {
+		return 32
+	}
+	nextPower := 1 << bits.Len(uint(length-1))
+	chunk := 32
+	if nextPower > 256 {
+		chunk = nextPower / 8
+	}
+	return chunk * ((length-1)/chunk + 1)
+}
+
+// nip44Pad prefixes the plaintext with its length and pads it with zeros
+func nip44Pad(plaintext string) ([]byte, error) {
+	length := len(plaintext)
+	if length < 1 || length > 65535 {
+		return nil, errors.New("plaintext length must be between 1 and 65535 bytes")
+	}
+
+	padded := make([]byte, 2+nip44PaddedLen(length))
+	binary.BigEndian.PutUint16(padded, uint16(length))
+	copy(padded[2:], plaintext)
+	return padded, nil
+}
+
+// nip44Unpad strips the length prefix and padding
+func nip44Unpad(padded []byte) (string, error) {
+	if len(padded) < 2 {
+		return "", errors.New("invalid padding")
+	}
+	length := int(binary.BigEndian.Uint16(padded))
+	if length < 1 || 2+length > len(padded) || len(padded) != 2+nip44PaddedLen(length) {
+		return "", errors.New("invalid padding")
+	}
+	return string(padded[2 : 2+length]), nil
+}
@@ -0,0 +1,117 @@
+package nostr
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+)
+
+// The vectors are from the official NIP-44 test vectors, nip44.vectors.json
+
+func testPrivKey(t *testing.T, privKeyHex string) *btcec.PrivateKey {
+	t.Helper()
+	privKeyBytes, err := hex.DecodeString(privKeyHex)
+	if err != nil {
+		t.Fatal(err)
+	}
+	privKey, _ := btcec.PrivKeyFromBytes(privKeyBytes)
+	return privKey
+}
+
+func TestNIP44ConversationKey(t *testing.T) {
+	tests := []struct {
+		sec1, pub2, want string
+	}{
+		{
+			"315e59ff51cb9209768cf7da80791ddcaae56ac9775eb25b6dee1234bc5d2268",
+			"c2f9d9948dc8c7c38321e4b85c8558872eafa0641cd269db76848a6073e69133",
+			"3dfef0ce2a4d80a25e7a328accf73448ef67096f65f79588e358d9a0eb9013f1",
+		},
+		{
+			"0000000000000000000000000000000000000000000000000000000000000001",
+			"c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5", // Pubkey of 0x02
+			"c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d",
+		},
+	}
+	for _, tt := range tests {
+		key, err := nip44ConversationKey(testPrivKey(t, tt.sec1), tt.pub2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := hex.EncodeToString(key); got != tt.want {
+			t.Errorf("nip44ConversationKey(%s, %s) = %s, want %s", tt.sec1, tt.pub2, got, tt.want)
+		}
+	}
+}
+
+func TestNIP44PaddedLen(t *testing.T) {
+	tests := [][2]int{
+		{16, 32}, {32, 32}, {33, 64}, {37, 64}, {45, 64}, {49, 64}, {64, 64}, {65, 96}, {100, 128},
+		{111, 128}, {200, 224}, {250, 256}, {320, 320}, {383, 384}, {384, 384}, {400, 448}, {500, 512},
+		{512, 512}, {515, 640}, {700, 768}, {800, 896}, {900, 1024}, {1020, 1024}, {65536, 65536},
+	}
+	for _, tt := range tests {
+		if got := nip44PaddedLen(tt[0]); got != tt[1] {
+			t.Errorf("nip44PaddedLen(%d) = %d, want %d", tt[0], got, tt[1])
+		}
+	}
+}
+
+const (
+	// Conversation key of the private keys 0x01 and 0x02
+	nip44TestKey     = "c41c775356fd92eadc63ff5a0dc1da211b268cbea22316767095b2871ea1412d"
+	nip44TestNonce   = "0000000000000000000000000000000000000000000000000000000000000001"
+	nip44TestPayload = "AgAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAABee0G5VSK0/9YypIObAtDKfYEAjD35uVkHyB0F4DwrcNaCXlCWZKaArsGrY6M9wnuTMxWfp1RTN9Xga8no+kF5Vsb"
+)
+
+func TestNIP44EncryptDecrypt(t *testing.T) {
+	key, _ := hex.DecodeString(nip44TestKey)
+	nonce, _ := hex.DecodeString(nip44TestNonce)
+
+	payload, err := nip44EncryptWithNonce(key, nonce, "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != nip44TestPayload {
+		t.Errorf("nip44EncryptWithNonce() = %s, want %s", payload, nip44TestPayload)
+	}
+
+	plaintext, err := nip44Decrypt(key, nip44TestPayload)
+	if err != nil || plaintext != "a" {
+		t.Errorf("nip44Decrypt() = %q, %v, want %q", plaintext, err, "a")
+	}
+}
+
+func TestNIP44RoundTrip(t *testing.T) {
+	key, _ := hex.DecodeString(nip44TestKey)
+	for _, length := range []int{1, 32, 33, 300, 65535} {
+		plaintext := strings.Repeat("x", length)
+		payload, err := nip44Encrypt(key, plaintext)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, err := nip44Decrypt(key, payload); err != nil || got != plaintext {
+			t.Errorf("nip44Decrypt() of %d bytes = %d bytes, %v", length, len(got), err)
+		}
+	}
+}
+
+func TestNIP44DecryptInvalidMAC(t *testing.T) {
+	key, _ := hex.DecodeString(nip44TestKey)
+	data, _ := base64.StdEncoding.DecodeString(nip44TestPayload)
+	data[len(data)-1] ^= 1
+
+	if _, err := nip44Decrypt(key, base64.StdEncoding.EncodeToString(data)); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("nip44Decrypt() = %v, want an invalid MAC error", err)
+	}
+
+	// A tampered ciphertext fails the MAC as well
+	data, _ = base64.StdEncoding.DecodeString(nip44TestPayload)
+	data[40] ^= 1
+	if _, err := nip44Decrypt(key, base64.StdEncoding.EncodeToString(data)); err == nil || !strings.Contains(err.Error(), "MAC") {
+		t.Errorf("nip44Decrypt() = %v, want an invalid MAC error", err)
+	}
+}
@@ -0,0 +1,325 @@
+package nostr
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// nip46Kind is the event kind used for Nostr Connect requests and responses
+	nip46Kind = 24133
+	// remoteSignerTimeout is how long to wait for the remote signer to answer a request
+	remoteSignerTimeout = 60 * time.Second
+)
+
+// RemoteSigner delegates signing to a NIP-46 remote signer (bunker) over a relay.
+// Requests are encrypted with NIP-44 using a client key that never leaves the bridge.
+type RemoteSigner struct {
+	signerPubkey string
+	relayURL     string
+	secret       string
+
+	clientKey       *btcec.PrivateKey
+	clientPubkey    string
+	conversationKey []byte
+
+	mu      sync.Mutex // Guards the connection and writes to it
+	ws      *websocket.Conn
+	pending sync.Map // Request ID to chan nip46Response
+}
+
+// nip46Request is the decrypted content of a request to the remote signer
+type nip46Request struct {
+	ID     string   `json:"id"`
+	Method string   `json:"method"`
+	Params []string `json:"params"`
+}
+
+// nip46Response is the decrypted content of a response from the remote signer
+type nip46Response struct {
+	ID     string `json:"id"`
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+}
+
+// NewRemoteSigner parses a bunker://<signer-pubkey>?relay=<wss://...>&secret=<secret> URL.
+// clientKeyHex persists the client identity between restarts; when empty a fresh key is generated,
+// which means the remote signer may ask to approve the bridge again.
+func NewRemoteSigner(bunkerURL, clientKeyHex string) (*RemoteSigner, error) {
+	u, err := url.Parse(bunkerURL)
+	if err != nil || u.Scheme != "bunker" {
+		return nil, fmt.Errorf("invalid bunker URL, expected bunker://<pubkey>?relay=<relay>")
+	}
+
+	rs := &RemoteSigner{
+		signerPubkey: u.Host,
+		relayURL:     u.Query().Get("relay"),
+		secret:       u.Query().Get("secret"),
+	}
+	if len(rs.signerPubkey) != 64 || rs.relayURL == "" {
+		return nil, fmt.Errorf("bunker URL must contain the signer's hex pubkey and a relay")
+	}
+
+	if clientKeyHex != "" {
+		keyBytes, err := hex.DecodeString(clientKeyHex)
+		if err != nil || len(keyBytes) != 32 {
+			return nil, fmt.Errorf("failed to decode bunker client key: expected 32 bytes of hex")
+		}
+		rs.clientKey, _ = btcec.PrivKeyFromBytes(keyBytes)
+	} else {
+		rs.clientKey, err = btcec.NewPrivateKey()
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate bunker client key: %w", err)
+		}
+	}
+	rs.clientPubkey = hex.EncodeToString(schnorr.SerializePubKey(rs.clientKey.PubKey()))
+
+	rs.conversationKey, err = nip44ConversationKey(rs.clientKey, rs.signerPubkey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive conversation key with remote signer: %w", err)
+	}
+
+	return rs, nil
+}
+
+// Connect introduces the bridge to the remote signer and returns the public key it signs for
+func (rs *RemoteSigner) Connect(ctx context.Context) (string, error) {
+	params := []string{rs.signerPubkey}
+	if rs.secret != "" {
+		params = append(params, rs.secret)
+	}
+
+	result, err := rs.request(ctx, "connect", params)
+	if err != nil {
+		return "", fmt.Errorf("remote signer connect failed: %w", err)
+	}
+	if result != "ack" && result != rs.secret {
+		return "", fmt.Errorf("remote signer connect failed: unexpected result %q", result)
+	}
+	log.Printf("Connected to remote signer %s", rs.signerPubkey)
+
+	pubkey, err := rs.request(ctx, "get_public_key", nil)
+	if err != nil {
+		return "", fmt.Errorf("remote signer get_public_key failed: %w", err)
+	}
+	return pubkey, nil
+}
+
+// SignEvent asks the remote signer to sign the event
+func (rs *RemoteSigner) SignEvent(ctx context.Context, event *NostrEvent) error {
+	unsigned, err := json.Marshal(map[string]interface{}{
+		"kind":       event.Kind,
+		"content":    event.Content,
+		"tags":       event.Tags,
+		"created_at": event.CreatedAt,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to serialize event for remote signer: %w", err)
+	}
+
+	result, err := rs.request(ctx, "sign_event", []string{string(unsigned)})
+	if err != nil {
+		return fmt.Errorf("remote signer sign_event failed: %w", err)
+	}
+
+	var signed NostrEvent
+	if err := json.Unmarshal([]byte(result), &signed); err != nil {
+		return fmt.Errorf("remote signer returned an invalid event: %w", err)
+	}
+	if signed.ID != event.ID || signed.Pubkey != event.Pubkey {
+		return fmt.Errorf("remote signer signed a different event (id %s, pubkey %s)", signed.ID, signed.Pubkey)
+	}
+
+	event.Sig = signed.Sig
+	return nil
+}
+
+// request sends a request to the remote signer and waits for its response
+func (rs *RemoteSigner) request(ctx context.Context, method string, params []string) (string, error) {
+	idBytes := make([]byte, 8)
+	if _, err := rand.Read(idBytes); err != nil {
+		return "", err
+	}
+	req := nip46Request{ID: hex.EncodeToString(idBytes), Method: method, Params: params}
+	if req.Params == nil {
+		req.Params = []string{}
+	}
+
+	reqJSON, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	content, err := nip44Encrypt(rs.conversationKey, string(reqJSON))
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt request: %w", err)
+	}
+
+	event := &NostrEvent{
+		Pubkey:    rs.clientPubkey,
+		CreatedAt: time.Now().Unix(),
+		Kind:      nip46Kind,
+		Tags:      [][]string{{"p", rs.signerPubkey}},
+		Content:   content,
+	}
+	if err := rs.clientSign(event); err != nil {
+		return "", err
+	}
+
+	responses := make(chan nip46Response, 1)
+	rs.pending.Store(req.ID, responses)
+	defer rs.pending.Delete(req.ID)
+
+	if err := rs.send(ctx, []interface{}{"EVENT", event}); err != nil {
+		return "", err
+	}
+	log.Printf("Sent %s request %s to remote signer", method, req.ID)
+
+	timeout := time.After(remoteSignerTimeout)
+	for {
+		select {
+		case resp := <-responses:
+			// The signer may ask the operator to approve the request in a browser first
+			if resp.Result == "auth_url" {
+				log.Printf("Remote signer requires approval, open: %s", resp.Error)
+				continue
+			}
+			if resp.Error != "" {
+				return "", errors.New(resp.Error)
+			}
+			return resp.Result, nil
+		case <-timeout:
+			return "", fmt.Errorf("no response from remote signer within %s", remoteSignerTimeout)
+		case <-ctx.Done():
+			return "", ctx.Err()
+		}
+	}
+}
+
+// clientSign computes the ID of the event and signs it with the client key
+func (rs *RemoteSigner) clientSign(event *NostrEvent) error {
+	eventStr, err := SerializeEventForID(*event)
+	if err != nil {
+		return err
+	}
+	event.ID = ComputeEventID(eventStr)
+
+	event.Sig, err = SignEventSchnorr(event.ID, rs.clientKey)
+	return err
+}
+
+// send writes a message to the relay, connecting and subscribing to responses first if needed
+func (rs *RemoteSigner) send(ctx context.Context, msg []interface{}) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+
+	if rs.ws == nil {
+		if err := rs.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	traceFrame(">", rs.relayURL, data)
+	if err := rs.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+		rs.ws.Close()
+		rs.ws = nil
+		return fmt.Errorf("failed to send request to remote signer relay: %w", err)
+	}
+	return nil
+}
+
+// connect dials the bunker relay and subscribes to responses addressed to the client key.
+// The caller must hold rs.mu.
+func (rs *RemoteSigner) connect(ctx context.Context) error {
+	ws, _, err := dialer.DialContext(ctx, rs.relayURL, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrDialFailed, err)
+	}
+
+	filter := map[string]interface{}{
+		"kinds": []int{nip46Kind},
+		"#p":    []string{rs.clientPubkey},
+		"since": time.Now().Add(-time.Minute).Unix(),
+	}
+	req, _ := json.Marshal([]interface{}{"REQ", "ndmbridge-nip46", filter})
+	traceFrame(">", rs.relayURL, req)
+	if err := ws.WriteMessage(websocket.TextMessage, req); err != nil {
+		ws.Close()
+		return fmt.Errorf("failed to subscribe to remote signer responses: %w", err)
+	}
+
+	rs.ws = ws
+	go rs.readLoop(ws)
+	log.Printf("Connected to remote signer relay %s", rs.relayURL)
+
+	return nil
+}
+
+// readLoop delivers decrypted responses from the remote signer to waiting requests
+func (rs *RemoteSigner) readLoop(ws *websocket.Conn) {
+	defer func() {
+		rs.mu.Lock()
+		if rs.ws == ws {
+			rs.ws = nil
+		}
+		rs.mu.Unlock()
+		ws.Close()
+	}()
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			log.Printf("Remote signer relay connection closed: %v", err)
+			return
+		}
+		traceFrame("<", rs.relayURL, message)
+
+		var frame []json.RawMessage
+		var label string
+		if json.Unmarshal(message, &frame) != nil || len(frame) < 3 ||
+			json.Unmarshal(frame[0], &label) != nil || label != "EVENT" {
+			continue
+		}
+
+		var event NostrEvent
+		if err := json.Unmarshal(frame[2], &event); err != nil || event.Kind != nip46Kind {
+			continue
+		}
+		if !strings.EqualFold(event.Pubkey, rs.signerPubkey) {
+			continue
+		}
+
+		plaintext, err := nip44Decrypt(rs.conversationKey, event.Content)
+		if err != nil {
+			log.Printf("Ignoring undecryptable remote signer message: %v", err)
+			continue
+		}
+
+		var resp nip46Response
+		if err := json.Unmarshal([]byte(plaintext), &resp); err != nil {
+			continue
+		}
+		if ch, ok := rs.pending.Load(resp.ID); ok {
+			select {
+			case ch.(chan nip46Response) <- resp:
+			default:
+			}
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const testBunkerKey = "0000000000000000000000000000000000000000000000000000000000000005"
+
+// startTestBunker starts a relay with a NIP-46 remote signer behind it that answers connect,
+// get_public_key and sign_event requests with testBunkerKey, and returns its ws:// URL
+func startTestBunker(t *testing.T) string {
+	t.Helper()
+	signerKey := testPrivKey(t, testBunkerKey)
+	signerPubkey, _ := DerivePublicKey(testBunkerKey)
+
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame []json.RawMessage
+			var label string
+			var event NostrEvent
+			if json.Unmarshal(message, &frame) != nil || len(frame) < 2 ||
+				json.Unmarshal(frame[0], &label) != nil || label != "EVENT" ||
+				json.Unmarshal(frame[1], &event) != nil {
+				continue
+			}
+			if err := VerifyEvent(event); err != nil {
+				t.Errorf("request event failed verification: %v", err)
+				return
+			}
+
+			conversationKey, err := nip44ConversationKey(signerKey, event.Pubkey)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			plaintext, err := nip44Decrypt(conversationKey, event.Content)
+			if err != nil {
+				t.Errorf("failed to decrypt request: %v", err)
+				return
+			}
+			var req nip46Request
+			if err := json.Unmarshal([]byte(plaintext), &req); err != nil {
+				t.Errorf("invalid request %s: %v", plaintext, err)
+				return
+			}
+
+			resp := nip46Response{ID: req.ID}
+			switch req.Method {
+			case "connect":
+				resp.Result = "ack"
+			case "get_public_key":
+				resp.Result = signerPubkey
+			case "sign_event":
+				var unsigned NostrEvent
+				json.Unmarshal([]byte(req.Params[0]), &unsigned)
+				unsigned.Pubkey = signerPubkey
+				eventStr, _ := serializeEvent(unsigned)
+				unsigned.ID = ComputeEventID(eventStr)
+				unsigned.Sig, _ = SignEventSchnorr(unsigned.ID, signerKey)
+				signed, _ := json.Marshal(unsigned)
+				resp.Result = string(signed)
+			default:
+				resp.Error = "unsupported method " + req.Method
+			}
+
+			respJSON, _ := json.Marshal(resp)
+			content, err := nip44Encrypt(conversationKey, string(respJSON))
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			reply := &NostrEvent{
+				Pubkey:    signerPubkey,
+				CreatedAt: time.Now().Unix(),
+				Kind:      nip46Kind,
+				Tags:      [][]string{{"p", event.Pubkey}},
+				Content:   content,
+			}
+			eventStr, _ := serializeEvent(*reply)
+			reply.ID = ComputeEventID(eventStr)
+			reply.Sig, _ = SignEventSchnorr(reply.ID, signerKey)
+			if err := ws.WriteJSON([]any{"EVENT", "ndmbridge-nip46", reply}); err != nil {
+				return
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func TestRemoteSignerRoundTrip(t *testing.T) {
+	relayURL := startTestBunker(t)
+	signerPubkey, _ := DerivePublicKey(testBunkerKey)
+	rs, err := NewRemoteSigner("bunker://"+signerPubkey+"?relay="+relayURL, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	pubkey, err := rs.Connect(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pubkey != signerPubkey {
+		t.Errorf("Connect() = %s, want %s", pubkey, signerPubkey)
+	}
+
+	event, err := CreateNostrEvent("signed remotely <3", pubkey, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rs.SignEvent(ctx, event); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyEvent(*event); err != nil {
+		t.Errorf("VerifyEvent() of the remotely signed event = %v", err)
+	}
+}
@@ -1,19 +1,21 @@
 package nostr
 
 import (
+	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
-	"regexp"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/btcsuite/btcd/btcec/v2"
 	"github.com/btcsuite/btcd/btcec/v2/schnorr"
-	"github.com/bwmarrin/discordgo"
-	"github.com/gorilla/websocket"
 )
 
 // NostrEvent represents a Nostr event
@@ -27,42 +29,86 @@ type NostrEvent struct {
 	Sig       string     `json:"sig"`
 }
 
-// PrepareMessageContent prepares the message content by removing all mentions and appending attachment URLs
-func PrepareMessageContent(m *discordgo.MessageCreate) string {
-	content := m.Content
+// ClientName and Version identify the bridge in the client tag of published events
+const (
+	ClientName = "ndmBridge"
+	Version    = "0.1.0"
+)
 
-	// Remove channel mentions (e.g., <#1067205302946111602>)
-	content = removeMentions(content, `<#[0-9]+>`)
+// clientTagEnabled controls whether CreateNostrEvent adds the client tag
+var clientTagEnabled = true
 
-	// Remove user mentions (e.g., <@UserID> or <@!UserID>)
-	content = removeMentions(content, `<@!?[0-9]+>`)
+// SetClientTag enables or disables the ["client", "ndmBridge", "<version>"] tag on created events
+func SetClientTag(enabled bool) {
+	clientTagEnabled = enabled
+}
 
-	// Remove role mentions (e.g., <@&RoleID>)
-	content = removeMentions(content, `<@&[0-9]+>`)
+// staticTags are appended to every event created by CreateNostrEvent
+var staticTags [][]string
 
-	for _, attachment := range m.Attachments {
-		decodedURL := strings.ReplaceAll(attachment.URL, "\\u0026", "&")
-		content += "\n" + decodedURL
-	}
+// SetStaticTags sets tags that CreateNostrEvent appends to every event.
+// It must be called before events are created.
+func SetStaticTags(tags [][]string) {
+	staticTags = tags
+}
 
-	log.Printf("Message content prepared after removing mentions: %s", content)
-	return content
+// monotonicCreatedAt makes CreateEvent give every event a later created_at than the previous one
+var (
+	monotonicCreatedAt bool
+	createdAtMu        sync.Mutex
+	lastCreatedAt      int64
+)
+
+// SetMonotonicCreatedAt enables strictly increasing created_at values, so events created within the
+// same second keep their order in clients that sort by created_at. A burst of events moves created_at
+// up to a few seconds ahead of the clock, which catches up once the burst is over.
+func SetMonotonicCreatedAt(enabled bool) {
+	monotonicCreatedAt = enabled
 }
 
-// removeMentions removes all matches of the given regex pattern from the content
-func removeMentions(content string, pattern string) string {
-	re := regexp.MustCompile(pattern)
-	return re.ReplaceAllString(content, "")
+// nextCreatedAt returns the created_at of a new event
+func nextCreatedAt() int64 {
+	now := time.Now().Unix()
+	if !monotonicCreatedAt {
+		return now
+	}
+	createdAtMu.Lock()
+	defer createdAtMu.Unlock()
+	if now <= lastCreatedAt {
+		now = lastCreatedAt + 1
+	}
+	lastCreatedAt = now
+	return now
+}
+
+// ReactionKind is the NIP-25 reaction event kind
+const ReactionKind = 7
+
+// CommentKind is the NIP-22 comment kind
+const CommentKind = 1111
+
+// MetadataKind is the NIP-01 profile metadata kind, replaced by every newer one
+const MetadataKind = 0
+
+// DeletionKind is the NIP-09 event deletion request kind
+const DeletionKind = 5
+
+// BookmarkSetKind is the NIP-51 bookmark set kind, a replaceable list identified by its d tag
+const BookmarkSetKind = 30003
+
+// CreateNostrEvent creates a kind-1 Nostr event with the given content, public key and tags
+func CreateNostrEvent(content, pubkey string, extraTags [][]string) (*NostrEvent, error) {
+	return CreateEvent(1, content, pubkey, extraTags)
 }
 
-// CreateNostrEvent creates a Nostr event with the given content and public key
-func CreateNostrEvent(content, pubkey string) (*NostrEvent, error) {
+// CreateEvent creates a Nostr event of the given kind with the content, public key and tags
+func CreateEvent(kind int, content, pubkey string, extraTags [][]string) (*NostrEvent, error) {
 	event := &NostrEvent{
 		Pubkey:    pubkey,
-		CreatedAt: time.Now().Unix(),
-		Kind:      1,
+		CreatedAt: nextCreatedAt(),
+		Kind:      kind,
 		Content:   content,
-		Tags:      [][]string{},
+		Tags:      eventTags(extraTags),
 	}
 
 	eventStr, err := SerializeEventForID(*event)
@@ -77,28 +123,115 @@ func CreateNostrEvent(content, pubkey string) (*NostrEvent, error) {
 	return event, nil
 }
 
+// eventTags returns the extra tags followed by the static tags and the client tag
+func eventTags(extraTags [][]string) [][]string {
+	tags := make([][]string, 0, len(extraTags)+len(staticTags)+1)
+	tags = append(tags, extraTags...)
+	tags = append(tags, staticTags...)
+	if clientTagEnabled && !hasTag(tags, "client") {
+		tags = append(tags, []string{"client", ClientName, Version})
+	}
+	return tags
+}
+
+// ReplyTags builds NIP-10 marked e tags for a reply to parentID in the thread started by rootID.
+// Direct replies to the root only carry the root marker.
+func ReplyTags(rootID, parentID, relayHint string) [][]string {
+	tags := [][]string{{"e", rootID, relayHint, "root"}}
+	if parentID != rootID {
+		tags = append(tags, []string{"e", parentID, relayHint, "reply"})
+	}
+	return tags
+}
+
+// CommentTags builds NIP-22 tags for a comment on the kind-1 note eventID by pubkey. A top-level
+// comment has the note as both its root (uppercase tags) and its parent (lowercase tags).
+func CommentTags(eventID, relayHint, pubkey string) [][]string {
+	return [][]string{
+		{"E", eventID, relayHint, pubkey},
+		{"K", "1"},
+		{"P", pubkey},
+		{"e", eventID, relayHint, pubkey},
+		{"k", "1"},
+		{"p", pubkey},
+	}
+}
+
+// hasTag reports whether tags contains a tag with the given name
+func hasTag(tags [][]string, name string) bool {
+	for _, tag := range tags {
+		if len(tag) > 0 && tag[0] == name {
+			return true
+		}
+	}
+	return false
+}
+
 // SerializeEventForID serializes the event into the format required by NIP-01 for ID computation
 func SerializeEventForID(event NostrEvent) (string, error) {
+	eventStr, err := serializeEvent(event)
+	if err != nil {
+		log.Printf("Error marshaling event: %v", err)
+		return "", err
+	}
+	log.Printf("Serialized event string: %s", eventStr)
+
+	return eventStr, nil
+}
+
+// serializeEvent is SerializeEventForID without logging, for callers that serialize in a loop
+func serializeEvent(event NostrEvent) (string, error) {
+	// NIP-01 requires an empty tag list to serialize as [], while a nil slice would marshal as null
+	// and change the ID
+	tags := event.Tags
+	if tags == nil {
+		tags = [][]string{}
+	}
+
 	serializedEvent := []interface{}{
 		0,
 		event.Pubkey,
 		event.CreatedAt,
 		event.Kind,
-		event.Tags,
+		tags,
 		event.Content,
 	}
 
-	eventBytes, err := json.Marshal(serializedEvent)
-	if err != nil {
-		log.Printf("Error marshaling event: %v", err)
+	// NIP-01 wants characters written as they are, so <, > and & must not be HTML-escaped
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(serializedEvent); err != nil {
 		return "", err
 	}
 
-	eventStr := string(eventBytes)
-	eventStr = strings.ReplaceAll(eventStr, "\\u0026", "&")
-	log.Printf("Serialized event string: %s", eventStr)
+	return unescapeLineSeparators(bytes.TrimSuffix(buf.Bytes(), []byte("\n"))), nil
+}
 
-	return eventStr, nil
+// unescapeLineSeparators writes back U+2028 and U+2029, which encoding/json always escapes, into
+// JSON text. Other escape sequences, like an escaped backslash followed by "u2028", are kept.
+func unescapeLineSeparators(data []byte) string {
+	var sb strings.Builder
+	sb.Grow(len(data))
+	for i := 0; i < len(data); i++ {
+		if data[i] != '\\' || i+1 == len(data) {
+			sb.WriteByte(data[i])
+			continue
+		}
+		switch string(data[i:min(i+6, len(data))]) {
+		case `\u2028`:
+			sb.WriteRune('\u2028')
+			i += 5
+			continue
+		case `\u2029`:
+			sb.WriteRune('\u2029')
+			i += 5
+			continue
+		}
+		sb.Write(data[i : i+2])
+		i++
+	}
+	return sb.String()
 }
 
 // ComputeEventID computes the ID for a given event
@@ -109,26 +242,200 @@ func ComputeEventID(serializedEvent string) string {
 	return eventID
 }
 
-// SignAndSendEvent signs the event and sends it to the Nostr relay
-func SignAndSendEvent(event *NostrEvent, privKeyHex, relayURL string) error {
-	privKeyBytes, err := hex.DecodeString(privKeyHex)
-	if err != nil {
-		log.Printf("Error decoding private key: %v", err)
-		return fmt.Errorf("failed to decode private key: %w", err)
+// SignAndSendEvent signs the event and sends it to every configured Nostr relay, returning the
+// result of each relay that was tried
+func SignAndSendEvent(ctx context.Context, event *NostrEvent, signer Signer, relayURLs []string) ([]RelayResult, error) {
+	// Relays that require proof of work all get the event mined to the highest difficulty among them
+	if difficulty := requiredPoW(relayURLs); difficulty > 0 {
+		if err := MinePoW(ctx, event, difficulty); err != nil {
+			return nil, fmt.Errorf("failed to mine proof of work: %w", err)
+		}
 	}
 
-	privKey, _ := btcec.PrivKeyFromBytes(privKeyBytes)
-	log.Println("Private key decoded successfully")
-
-	sig, err := SignEventSchnorr(event.ID, privKey)
-	if err != nil {
+	if err := signer.SignEvent(ctx, event); err != nil {
 		log.Printf("Error signing event: %v", err)
-		return fmt.Errorf("failed to sign event: %v", err)
+		return nil, fmt.Errorf("%w: %v", ErrSignFailed, err)
 	}
-	event.Sig = sig
 	log.Printf("Event signed with Schnorr signature: %s", event.Sig)
 
-	return SendEvent(relayURL, *event)
+	return PublishEvent(ctx, *event, relayURLs)
+}
+
+// publishSlots bounds the number of events being published at once, nil means unlimited
+var publishSlots chan struct{}
+
+// SetMaxInFlight limits how many events may be published concurrently across all relays.
+// It must be called before publishing starts; zero or less removes the limit.
+func SetMaxInFlight(n int) {
+	if n <= 0 {
+		publishSlots = nil
+		return
+	}
+	publishSlots = make(chan struct{}, n)
+}
+
+var (
+	// queueDepth counts PublishEvent calls that are waiting for a slot or publishing
+	queueDepth atomic.Int64
+	// queueHighWater is the depth above which a warning is logged, zero disables the warning
+	queueHighWater int64
+	// aboveHighWater remembers whether the depth already crossed the mark, so the warning is logged once per crossing
+	aboveHighWater atomic.Bool
+)
+
+// SetQueueHighWater sets the publish queue depth above which a warning is logged.
+// It must be called before publishing starts; zero or less disables the warning.
+func SetQueueHighWater(n int) {
+	queueHighWater = int64(max(n, 0))
+}
+
+// QueueDepth returns the number of events currently waiting to be published or being published
+func QueueDepth() int {
+	return int(queueDepth.Load())
+}
+
+// trackQueue counts the event as queued and warns when the queue grows past the high-water mark.
+// The returned function removes it from the queue again.
+func trackQueue(eventID string) func() {
+	depth := queueDepth.Add(1)
+	if queueHighWater > 0 && depth > queueHighWater && aboveHighWater.CompareAndSwap(false, true) {
+		log.Printf("Publish queue depth %d exceeds the high-water mark of %d while queueing event %s, a relay may be stuck", depth, queueHighWater, eventID)
+	}
+
+	return func() {
+		if depth := queueDepth.Add(-1); depth <= queueHighWater && aboveHighWater.CompareAndSwap(true, false) {
+			log.Printf("Publish queue depth back to %d", depth)
+		}
+	}
+}
+
+// relayTimeout bounds how long PublishEvent waits for a single relay, zero means no limit
+var relayTimeout time.Duration
+
+// SetRelayTimeout sets how long PublishEvent waits for each relay to accept an event before counting
+// it as failed. It must be called before publishing starts; zero or less removes the limit.
+func SetRelayTimeout(d time.Duration) {
+	relayTimeout = max(d, 0)
+}
+
+// RelayResult is the outcome of publishing an event to one relay, Err is nil when the relay accepted it
+type RelayResult struct {
+	Relay string
+	Err   error
+}
+
+// publishQuorum is the number of relays that must accept an event for publishing to succeed
+var publishQuorum = 1
+
+// SetPublishQuorum sets how many relays must accept an event for PublishEvent to succeed.
+// It must be called before publishing starts; values below one are treated as one.
+func SetPublishQuorum(n int) {
+	publishQuorum = max(n, 1)
+}
+
+// PublishEvent sends the event to all relays concurrently, each bounded by the relay timeout, and
+// succeeds if at least the quorum of relays accepted it. It returns once every relay has answered or timed out,
+// with the results in the order of relayURLs.
+func PublishEvent(ctx context.Context, event NostrEvent, relayURLs []string) ([]RelayResult, error) {
+	defer trackQueue(event.ID)()
+
+	if publishSlots != nil {
+		select {
+		case publishSlots <- struct{}{}:
+			defer func() { <-publishSlots }()
+		case <-ctx.Done():
+			return nil, fmt.Errorf("waiting to publish event: %w", ctx.Err())
+		}
+	}
+
+	results := make([]RelayResult, len(relayURLs))
+	var wg sync.WaitGroup
+	for i, relayURL := range relayURLs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = RelayResult{Relay: relayURL, Err: publishToRelay(ctx, relayURL, event)}
+		}()
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, result := range results {
+		if result.Err != nil {
+			errs = append(errs, result.Err)
+		}
+	}
+
+	// With fewer relays than the quorum, for example for a kind routed to a few relays, all must accept
+	accepted := len(relayURLs) - len(errs)
+	if required := min(publishQuorum, len(relayURLs)); accepted < required {
+		if accepted == 0 && allOverLimit(errs) {
+			return results, fmt.Errorf("%w: %w", ErrEventTooLarge, errors.Join(errs...))
+		}
+		if accepted == 0 {
+			return results, fmt.Errorf("failed to publish event to any relay: %w", errors.Join(errs...))
+		}
+		return results, fmt.Errorf("event accepted by %d relays, fewer than the quorum of %d: %w", accepted, required, errors.Join(errs...))
+	}
+
+	return results, nil
+}
+
+// publishToRelay checks the event against the relay's limits and sends it, retrying failed
+// attempts as the retry policy allows. Each attempt is bounded by the relay timeout.
+func publishToRelay(ctx context.Context, relayURL string, event NostrEvent) error {
+	if err := checkEventSize(relayURL, event); err != nil {
+		log.Printf("Not sending event %s to %s: %v", event.ID, relayURL, err)
+		return err
+	}
+	if err := checkRelayLimits(relayURL, event); err != nil {
+		log.Printf("Event %s exceeds the limits of %s: %v", event.ID, relayURL, err)
+		if skipOverLimit {
+			return err
+		}
+	}
+
+	policy := retryPolicy
+	var err error
+	for attempt := 1; ; attempt++ {
+		// A relay whose circuit opened is skipped, also between retries of this event
+		if !allowAttempt(relayURL) {
+			if err == nil {
+				err = &RelayError{Relay: relayURL, Err: ErrCircuitOpen}
+				log.Printf("Not sending event %s to %s: %v", event.ID, relayURL, err)
+			}
+			return err
+		}
+
+		err = sendWithTimeout(ctx, relayURL, event)
+		recordPublish(relayURL, err)
+		if err == nil {
+			return nil
+		}
+		log.Printf("Error publishing event %s to %s: %v", event.ID, relayURL, err)
+
+		if attempt >= policy.Attempts || !policy.isRetryable(err) {
+			return err
+		}
+
+		wait := policy.backoff(attempt)
+		log.Printf("Retrying event %s on %s in %s (attempt %d of %d)", event.ID, relayURL, wait, attempt+1, policy.Attempts)
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return err
+		}
+	}
+}
+
+// sendWithTimeout sends the event to the relay within the relay timeout
+func sendWithTimeout(ctx context.Context, relayURL string, event NostrEvent) error {
+	if relayTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, relayTimeout)
+		defer cancel()
+	}
+	return SendEvent(ctx, relayURL, event)
 }
 
 // SignEventSchnorr signs the event ID using Schnorr signatures
@@ -150,38 +457,3 @@ func SignEventSchnorr(eventID string, privKey *btcec.PrivateKey) (string, error)
 
 	return sigStr, nil
 }
-
-// SendEvent sends the event to the Nostr relay via WebSocket and reads the server's response
-func SendEvent(relayURL string, event NostrEvent) error {
-	ws, _, err := websocket.DefaultDialer.Dial(relayURL, nil)
-	if err != nil {
-		log.Printf("Error connecting to Nostr relay: %v", err)
-		return fmt.Errorf("error connecting to Nostr relay: %v", err)
-	}
-	defer ws.Close()
-	log.Println("Connected to Nostr relay successfully")
-
-	msg := []interface{}{"EVENT", event}
-	eventJSON, err := json.Marshal(msg)
-	if err != nil {
-		log.Printf("Error serializing event: %v", err)
-		return fmt.Errorf("failed to serialize event: %v", err)
-	}
-
-	log.Printf("Sending event to relay: %s", eventJSON)
-	err = ws.WriteMessage(websocket.TextMessage, eventJSON)
-	if err != nil {
-		log.Printf("Error sending event: %v", err)
-		return fmt.Errorf("failed to send event: %v", err)
-	}
-
-	_, message, err := ws.ReadMessage()
-	if err != nil {
-		log.Printf("Error reading response from relay: %v", err)
-		return fmt.Errorf("failed to read response from relay: %v", err)
-	}
-
-	log.Printf("Received response from relay: %s", string(message))
-
-	return nil
-}
\ No newline at end of file
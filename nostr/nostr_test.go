@@ -0,0 +1,46 @@
+package nostr
+
+import "testing"
+
+func TestSerializeEventCanonical(t *testing.T) {
+	event := NostrEvent{
+		Pubkey:    "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d",
+		CreatedAt: 1700000000,
+		Kind:      1,
+		Tags:      [][]string{{"t", "<b>&amp;"}},
+		Content:   "a <3 & b > c\u2028d \\u2029 \"q\"\n",
+	}
+	want := `[0,"3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d",1700000000,1,[["t","<b>&amp;"]],"a <3 & b > c` + "\u2028" + `d \\u2029 \"q\"\n"]`
+
+	got, err := serializeEvent(event)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("serializeEvent() = %s, want %s", got, want)
+	}
+	// Computed independently from the NIP-01 serialization
+	if id := ComputeEventID(got); id != "a3c32ebd8f78611e8aab6dabd97ea72dfd7c551eef9ae75e6946278c776e5eda" {
+		t.Errorf("ComputeEventID() = %s, want the NIP-01 ID", id)
+	}
+}
+
+func TestSerializeEventEmptyTags(t *testing.T) {
+	want := `[0,"3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d",1700000000,1,[],"hello"]`
+	for name, tags := range map[string][][]string{"nil": nil, "empty": {}} {
+		event := NostrEvent{
+			Pubkey:    "3bf0c63fcb93463407af97a5e5ee64fa883d107ef9e558472c4eb9aaaefa459d",
+			CreatedAt: 1700000000,
+			Kind:      1,
+			Tags:      tags,
+			Content:   "hello",
+		}
+		got, err := serializeEvent(event)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want {
+			t.Errorf("serializeEvent() with %s tags = %s, want %s", name, got, want)
+		}
+	}
+}
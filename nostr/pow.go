@@ -0,0 +1,88 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"math/bits"
+	"strconv"
+)
+
+// MaxPoWDifficulty is the highest NIP-13 difficulty the bridge will mine. Relays requiring more are
+// still sent events, which they will likely reject.
+const MaxPoWDifficulty = 24
+
+// MinePoW adds a NIP-13 nonce tag to the event and searches for a nonce whose event ID has at least
+// difficulty leading zero bits. The event ID is updated; the event must be signed afterwards.
+func MinePoW(ctx context.Context, event *NostrEvent, difficulty int) error {
+	tags := make([][]string, 0, len(event.Tags)+1)
+	for _, tag := range event.Tags {
+		if len(tag) > 0 && tag[0] == "nonce" {
+			continue
+		}
+		tags = append(tags, tag)
+	}
+	nonceTag := []string{"nonce", "0", strconv.Itoa(difficulty)}
+	event.Tags = append(tags, nonceTag)
+
+	for nonce := 0; ; nonce++ {
+		if nonce%10000 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		nonceTag[1] = strconv.Itoa(nonce)
+		eventStr, err := serializeEvent(*event)
+		if err != nil {
+			return fmt.Errorf("failed to serialize event: %w", err)
+		}
+
+		hash := sha256.Sum256([]byte(eventStr))
+		if leadingZeroBits(hash[:]) >= difficulty {
+			event.ID = hex.EncodeToString(hash[:])
+			log.Printf("Mined proof of work of difficulty %d after %d attempts: %s", difficulty, nonce+1, event.ID)
+			return nil
+		}
+	}
+}
+
+// leadingZeroBits counts the leading zero bits of the hash
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
+
+// relayPoW holds configured minimum difficulties per relay URL, for relays that don't advertise one
+var relayPoW = make(map[string]int)
+
+// SetRelayPoW sets the minimum proof of work difficulty of individual relays. A relay's difficulty is
+// the higher of this and the one it advertises over NIP-11. It must be called before publishing starts.
+func SetRelayPoW(difficulties map[string]int) {
+	relayPoW = make(map[string]int, len(difficulties))
+	for relayURL, difficulty := range difficulties {
+		if difficulty > MaxPoWDifficulty {
+			log.Printf("Proof of work difficulty %d for %s exceeds %d, mining to %d", difficulty, relayURL, MaxPoWDifficulty, MaxPoWDifficulty)
+		}
+		relayPoW[relayURL] = difficulty
+	}
+}
+
+// requiredPoW returns the highest proof of work difficulty among the relays, capped at MaxPoWDifficulty.
+// It is zero, so no mining happens, when none of the relays require proof of work.
+func requiredPoW(relayURLs []string) int {
+	difficulty := 0
+	for _, relayURL := range relayURLs {
+		d := max(relayLimits[relayURL].MinPowDifficulty, relayPoW[relayURL])
+		if d > difficulty {
+			difficulty = d
+		}
+	}
+	return min(difficulty, MaxPoWDifficulty)
+}
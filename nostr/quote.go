@@ -0,0 +1,53 @@
+package nostr
+
+import (
+	"log"
+	"regexp"
+	"strings"
+)
+
+// eventRefRe matches note1… and nevent1… identifiers that stand on their own or follow nostr:,
+// leaving out the ones inside links such as njump.me URLs
+var eventRefRe = regexp.MustCompile(`(^|[\s(])(nostr:)?((?:note|nevent)1[02-9ac-hj-np-z]+)`)
+
+// QuoteReferences turns the note and nevent identifiers in the content into NIP-21 nostr: mentions
+// and returns a NIP-18 q tag for every event they quote. Identifiers that don't decode are left as
+// they are.
+func QuoteReferences(content string) (string, [][]string) {
+	var sb strings.Builder
+	var tags [][]string
+	quoted := make(map[string]int) // Index of each quoted event's tag
+	last := 0
+	for _, match := range eventRefRe.FindAllStringSubmatchIndex(content, -1) {
+		ref := content[match[6]:match[7]]
+		pointer, err := DecodeEventPointer(ref)
+		if err != nil {
+			log.Printf("Ignoring invalid Nostr reference %s: %v", ref, err)
+			continue
+		}
+
+		// Clients only render the quoted note for nostr: mentions
+		sb.WriteString(content[last:match[6]])
+		if match[4] < 0 {
+			sb.WriteString("nostr:")
+		}
+		sb.WriteString(ref)
+		last = match[7]
+
+		// An event quoted more than once gets one tag, with the hints of whichever reference has them
+		i, ok := quoted[pointer.ID]
+		if !ok {
+			i = len(tags)
+			quoted[pointer.ID] = i
+			tags = append(tags, []string{"q", pointer.ID, ""})
+		}
+		if tags[i][2] == "" && len(pointer.Relays) > 0 {
+			tags[i][2] = pointer.Relays[0]
+		}
+		if len(tags[i]) == 3 && pointer.Author != "" {
+			tags[i] = append(tags[i], pointer.Author)
+		}
+	}
+	sb.WriteString(content[last:])
+	return sb.String(), tags
+}
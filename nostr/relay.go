@@ -0,0 +1,304 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// DefaultOKTimeout is how long SendEvent waits for the relay's OK when the caller's context has no deadline
+const DefaultOKTimeout = 30 * time.Second
+
+// frameBuffer is how many unread relay frames are kept per connection before new ones are dropped
+const frameBuffer = 32
+
+// relayConn is a persistent connection to a relay that is reused across publishes.
+// A background reader delivers incoming frames so a close sent by the relay is noticed
+// even while no publish is waiting for a response.
+type relayConn struct {
+	url string
+
+	lock   chan struct{} // Held for the whole publish so responses aren't mixed up
+	ws     *websocket.Conn
+	frames chan []byte
+	closed chan struct{}
+
+	authenticated bool // Whether an AUTH challenge was answered on the current connection
+}
+
+var (
+	relayConnsMu sync.Mutex
+	relayConns   = make(map[string]*relayConn)
+)
+
+// getRelayConn returns the shared connection for the relay, creating it if needed
+func getRelayConn(relayURL string) *relayConn {
+	relayConnsMu.Lock()
+	defer relayConnsMu.Unlock()
+
+	rc, ok := relayConns[relayURL]
+	if !ok {
+		rc = &relayConn{url: relayURL, lock: make(chan struct{}, 1)}
+		relayConns[relayURL] = rc
+	}
+	return rc
+}
+
+// CloseRelays closes every open relay connection
+func CloseRelays() {
+	relayConnsMu.Lock()
+	defer relayConnsMu.Unlock()
+
+	for _, rc := range relayConns {
+		rc.lock <- struct{}{}
+		if rc.ws != nil {
+			rc.ws.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+			rc.ws.Close()
+			rc.ws = nil
+		}
+		<-rc.lock
+	}
+	log.Println("Relay connections closed")
+}
+
+// acquire takes the connection lock, giving up when ctx is done
+func (rc *relayConn) acquire(ctx context.Context) error {
+	select {
+	case rc.lock <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// release gives up the connection lock
+func (rc *relayConn) release() {
+	<-rc.lock
+}
+
+// connect dials the relay and starts the background reader. The caller must hold the lock.
+func (rc *relayConn) connect(ctx context.Context) error {
+	ws, _, err := dialer.DialContext(ctx, rc.url, relayHeader(rc.url))
+	if err != nil {
+		log.Printf("Error connecting to Nostr relay: %v", err)
+		return &RelayError{Relay: rc.url, Err: fmt.Errorf("%w: %v", ErrDialFailed, err)}
+	}
+	log.Printf("Connected to Nostr relay %s successfully", rc.url)
+
+	rc.ws = ws
+	rc.authenticated = false
+	rc.frames = make(chan []byte, frameBuffer)
+	rc.closed = make(chan struct{})
+	startKeepalive(ws, rc.url, rc.closed)
+	go rc.readLoop(ws, rc.frames, rc.closed)
+
+	return nil
+}
+
+// readLoop reads frames from the connection until it is closed
+func (rc *relayConn) readLoop(ws *websocket.Conn, frames chan<- []byte, closed chan<- struct{}) {
+	defer close(closed)
+	defer ws.Close()
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if err != nil {
+			// Some relays close the socket right after OK; that's not a failure, we reconnect on the next publish
+			if websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("Relay %s closed the connection", rc.url)
+			} else {
+				log.Printf("Error reading from relay %s: %v", rc.url, err)
+			}
+			return
+		}
+		traceFrame("<", rc.url, message)
+
+		select {
+		case frames <- message:
+		default:
+			log.Printf("Dropping unread frame from relay %s: %s", rc.url, string(message))
+		}
+	}
+}
+
+// isClosed reports whether the current connection has gone away. The caller must hold the lock.
+func (rc *relayConn) isClosed() bool {
+	if rc.ws == nil {
+		return true
+	}
+	select {
+	case <-rc.closed:
+		return true
+	default:
+		return false
+	}
+}
+
+// SendEvent sends the event to the Nostr relay via WebSocket and reads the server's response.
+// The connection is kept open and reused by later calls for the same relay.
+// Cancelling ctx or reaching its deadline aborts the dial, the write and the wait for OK. Without a
+// deadline on ctx, SendEvent gives up after DefaultOKTimeout. Either way a relay that never sends the
+// OK for this event, however many other frames it sends, fails with ErrRelayTimeout.
+func SendEvent(ctx context.Context, relayURL string, event NostrEvent) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultOKTimeout)
+		defer cancel()
+	}
+
+	rc := getRelayConn(relayURL)
+	if err := rc.acquire(ctx); err != nil {
+		return &RelayError{Relay: relayURL, Err: contextError(err)}
+	}
+	defer rc.release()
+
+	reused := !rc.isClosed()
+	err := rc.publish(ctx, event)
+	if err == errConnectionClosed && reused {
+		// The relay dropped an idle connection before answering, retry once on a fresh one
+		log.Printf("Relay %s closed the reused connection, reconnecting", relayURL)
+		err = rc.publish(ctx, event)
+	}
+	var relayErr *RelayError
+	if errors.As(err, &relayErr) && isAuthRequired(relayErr.Reason) && rc.authenticated {
+		// The relay answered before it saw our AUTH response, send the event again
+		log.Printf("Relay %s required authentication, resending event", relayURL)
+		err = rc.publish(ctx, event)
+	}
+	if err == errConnectionClosed {
+		return &RelayError{Relay: relayURL, Err: fmt.Errorf("failed to read response from relay: %w", err)}
+	}
+	return err
+}
+
+// errConnectionClosed is returned by publish when the connection closed before the relay answered
+var errConnectionClosed = errors.New("connection closed by relay")
+
+// publish writes the event and waits for the matching OK. The caller must hold the lock.
+func (rc *relayConn) publish(ctx context.Context, event NostrEvent) error {
+	if rc.isClosed() {
+		if err := rc.connect(ctx); err != nil {
+			return err
+		}
+	}
+
+	// Send empty tags as [] like the ID was computed with, relays reject null
+	if event.Tags == nil {
+		event.Tags = [][]string{}
+	}
+	msg := []interface{}{"EVENT", event}
+	eventJSON, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("Error serializing event: %v", err)
+		return fmt.Errorf("failed to serialize event: %v", err)
+	}
+
+	log.Printf("Sending event to relay: %s", eventJSON)
+	if deadline, ok := ctx.Deadline(); ok {
+		rc.ws.SetWriteDeadline(deadline)
+	} else {
+		rc.ws.SetWriteDeadline(time.Time{})
+	}
+	traceFrame(">", rc.url, eventJSON)
+	err = rc.ws.WriteMessage(websocket.TextMessage, eventJSON)
+	if err != nil {
+		log.Printf("Error sending event: %v", err)
+		rc.ws.Close()
+		rc.ws = nil
+		if isTimeout(err) {
+			return &RelayError{Relay: rc.url, Err: fmt.Errorf("%w: %v", ErrRelayTimeout, err)}
+		}
+		return errConnectionClosed
+	}
+
+	// Keep reading until the relay answers with an OK for our event. NOTICEs, OKs for other events
+	// and any other frames are logged and skipped; only the deadline ends the wait early.
+	for {
+		var message []byte
+		select {
+		case message = <-rc.frames:
+		case <-ctx.Done():
+			return &RelayError{Relay: rc.url, Err: contextError(ctx.Err())}
+		case <-rc.closed:
+			// Frames read before the close may still be buffered, like an OK followed by a close
+			select {
+			case message = <-rc.frames:
+			default:
+				return errConnectionClosed
+			}
+		}
+
+		log.Printf("Received response from relay: %s", string(message))
+
+		if challenge, ok := authChallenge(message); ok {
+			if err := rc.authenticate(ctx, challenge); err != nil {
+				log.Printf("Error answering AUTH challenge from %s: %v", rc.url, err)
+			}
+			continue
+		}
+
+		done, accepted, reason := handleRelayMessage(message, event.ID)
+		if !done {
+			continue
+		}
+		if !accepted {
+			return &RelayError{Relay: rc.url, Reason: reason, Err: ErrRelayRejected}
+		}
+		return nil
+	}
+}
+
+// handleRelayMessage processes a single relay message received after publishing an event.
+// It reports done once the OK for eventID has arrived, along with the relay's verdict and reason.
+func handleRelayMessage(message []byte, eventID string) (done, accepted bool, reason string) {
+	var frame []json.RawMessage
+	if err := json.Unmarshal(message, &frame); err != nil || len(frame) == 0 {
+		log.Printf("Ignoring malformed relay message: %s", string(message))
+		return false, false, ""
+	}
+
+	var label string
+	if err := json.Unmarshal(frame[0], &label); err != nil {
+		log.Printf("Ignoring relay message with invalid label: %s", string(message))
+		return false, false, ""
+	}
+
+	switch label {
+	case "OK":
+		var id string
+		if len(frame) < 3 ||
+			json.Unmarshal(frame[1], &id) != nil ||
+			json.Unmarshal(frame[2], &accepted) != nil {
+			log.Printf("Ignoring malformed OK message: %s", string(message))
+			return false, false, ""
+		}
+		if len(frame) > 3 {
+			json.Unmarshal(frame[3], &reason)
+		}
+
+		if id != eventID {
+			log.Printf("Skipping OK for unrelated event %s", id)
+			return false, false, ""
+		}
+		if !accepted {
+			log.Printf("Relay rejected event %s: %s", id, reason)
+		} else {
+			log.Printf("Relay accepted event %s", id)
+		}
+		return true, accepted, reason
+	case "NOTICE":
+		var notice string
+		if len(frame) > 1 {
+			json.Unmarshal(frame[1], &notice)
+		}
+		log.Printf("Relay notice: %s", notice)
+	}
+
+	return false, false, ""
+}
@@ -0,0 +1,96 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// startTestRelay starts a WebSocket relay that answers every EVENT with the frames respond returns
+// for the event's ID, and returns its ws:// URL
+func startTestRelay(t *testing.T, respond func(id string) []any) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			_, message, err := ws.ReadMessage()
+			if err != nil {
+				return
+			}
+			var frame []json.RawMessage
+			var event NostrEvent
+			if json.Unmarshal(message, &frame) != nil || len(frame) < 2 || json.Unmarshal(frame[1], &event) != nil {
+				continue
+			}
+			for _, reply := range respond(event.ID) {
+				if err := ws.WriteJSON(reply); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(func() {
+		CloseRelays()
+		server.Close()
+	})
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func testEvent(t *testing.T) NostrEvent {
+	t.Helper()
+	event, err := CreateNostrEvent("relay test", strings.Repeat("a", 64), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return *event
+}
+
+func sendTestEvent(t *testing.T, relayURL string, event NostrEvent) error {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return SendEvent(ctx, relayURL, event)
+}
+
+func TestSendEventAccepted(t *testing.T) {
+	relayURL := startTestRelay(t, func(id string) []any {
+		return []any{[]any{"OK", id, true, ""}}
+	})
+	if err := sendTestEvent(t, relayURL, testEvent(t)); err != nil {
+		t.Fatalf("SendEvent() = %v, want nil", err)
+	}
+}
+
+func TestSendEventRejected(t *testing.T) {
+	relayURL := startTestRelay(t, func(id string) []any {
+		return []any{[]any{"OK", id, false, "blocked: not on the allow list"}}
+	})
+	err := sendTestEvent(t, relayURL, testEvent(t))
+	if err == nil || !strings.Contains(err.Error(), "blocked") {
+		t.Fatalf("SendEvent() = %v, want an error containing %q", err, "blocked")
+	}
+}
+
+func TestSendEventSkipsOtherOK(t *testing.T) {
+	relayURL := startTestRelay(t, func(id string) []any {
+		return []any{
+			[]any{"OK", strings.Repeat("0", 64), false, "blocked: another event"},
+			[]any{"NOTICE", "unrelated"},
+			[]any{"OK", id, true, ""},
+		}
+	})
+	if err := sendTestEvent(t, relayURL, testEvent(t)); err != nil {
+		t.Fatalf("SendEvent() = %v, want nil after skipping the OK for another event", err)
+	}
+}
@@ -0,0 +1,52 @@
+package nostr
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// RetryPolicy controls how often PublishEvent tries a relay again after a failed attempt
+type RetryPolicy struct {
+	// Attempts is the total number of attempts per relay, one or less means no retries
+	Attempts int
+	// Backoff is the wait before the first retry, doubled for every retry after it
+	Backoff time.Duration
+	// RetryableReasons are substrings of relay rejection reasons worth retrying, such as "rate-limited:".
+	// Rejections matching none of them are permanent and never retried.
+	RetryableReasons []string
+}
+
+// retryPolicy is the policy used by PublishEvent, set with SetRetryPolicy
+var retryPolicy = RetryPolicy{Attempts: 1}
+
+// SetRetryPolicy sets how PublishEvent retries failed relays. Network errors and timeouts are
+// retried up to the attempt limit; rejections only when their reason is listed as retryable.
+// It must be called before publishing starts.
+func SetRetryPolicy(policy RetryPolicy) {
+	retryPolicy = policy
+}
+
+// isRetryable reports whether a failed attempt is worth repeating under the policy
+func (p RetryPolicy) isRetryable(err error) bool {
+	var relayErr *RelayError
+	switch {
+	case errors.Is(err, ErrOverRelayLimit), errors.Is(err, ErrSignFailed):
+		return false
+	case errors.Is(err, ErrRelayRejected) && errors.As(err, &relayErr):
+		for _, reason := range p.RetryableReasons {
+			if reason != "" && strings.Contains(relayErr.Reason, reason) {
+				return true
+			}
+		}
+		return false
+	default:
+		// Dial failures, timeouts and dropped connections are network errors
+		return true
+	}
+}
+
+// backoff returns the wait before the given retry, counting from one
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	return p.Backoff << (retry - 1)
+}
@@ -0,0 +1,96 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log"
+
+	"github.com/btcsuite/btcd/btcec/v2"
+	"github.com/btcsuite/btcd/btcec/v2/schnorr"
+)
+
+// Signer signs events on behalf of the bridge identity
+type Signer interface {
+	// SignEvent sets the event's signature
+	SignEvent(ctx context.Context, event *NostrEvent) error
+}
+
+// KeySigner signs events with a private key held locally
+type KeySigner struct {
+	privKey *btcec.PrivateKey
+}
+
+// NewKeySigner creates a signer from a hex encoded private key
+func NewKeySigner(privKeyHex string) (*KeySigner, error) {
+	privKeyBytes, err := hex.DecodeString(privKeyHex)
+	if err != nil || len(privKeyBytes) != 32 {
+		return nil, fmt.Errorf("failed to decode private key: expected 32 bytes of hex")
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(privKeyBytes)
+	log.Println("Private key decoded successfully")
+
+	return &KeySigner{privKey: privKey}, nil
+}
+
+// DerivePublicKey returns the hex encoded x-only public key of the hex private key
+func DerivePublicKey(privKeyHex string) (string, error) {
+	privKeyBytes, err := hex.DecodeString(privKeyHex)
+	if err != nil || len(privKeyBytes) != 32 {
+		return "", fmt.Errorf("failed to decode private key: expected 32 bytes of hex")
+	}
+
+	privKey, _ := btcec.PrivKeyFromBytes(privKeyBytes)
+	return hex.EncodeToString(schnorr.SerializePubKey(privKey.PubKey())), nil
+}
+
+// PublicKey returns the hex encoded x-only public key of the signer
+func (k *KeySigner) PublicKey() string {
+	return hex.EncodeToString(schnorr.SerializePubKey(k.privKey.PubKey()))
+}
+
+// SignEvent signs the event ID with the local key
+func (k *KeySigner) SignEvent(ctx context.Context, event *NostrEvent) error {
+	sig, err := SignEventSchnorr(event.ID, k.privKey)
+	if err != nil {
+		return err
+	}
+	event.Sig = sig
+	return nil
+}
+
+// VerifyEvent checks that the event ID matches its content and that the signature is valid for its pubkey
+func VerifyEvent(event NostrEvent) error {
+	pubkeyBytes, err := hex.DecodeString(event.Pubkey)
+	if err != nil {
+		return fmt.Errorf("failed to decode pubkey: %w", err)
+	}
+	pubkey, err := schnorr.ParsePubKey(pubkeyBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse pubkey: %w", err)
+	}
+
+	eventStr, err := serializeEvent(event)
+	if err != nil {
+		return fmt.Errorf("failed to serialize event: %w", err)
+	}
+	hash := sha256.Sum256([]byte(eventStr))
+	if hex.EncodeToString(hash[:]) != event.ID {
+		return fmt.Errorf("event ID does not match its content")
+	}
+
+	sigBytes, err := hex.DecodeString(event.Sig)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+	sig, err := schnorr.ParseSignature(sigBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse signature: %w", err)
+	}
+	if !sig.Verify(hash[:], pubkey) {
+		return fmt.Errorf("signature is not valid for pubkey %s", event.Pubkey)
+	}
+	return nil
+}
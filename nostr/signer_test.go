@@ -0,0 +1,45 @@
+package nostr
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+)
+
+// signedTestEvent returns an event whose ID is computed from its NIP-01 serialization written out by
+// hand, so the check doesn't depend on serializeEvent being right
+func signedTestEvent(t *testing.T) NostrEvent {
+	t.Helper()
+	signer, err := NewKeySigner("0000000000000000000000000000000000000000000000000000000000000003")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pubkey := signer.PublicKey()
+	hash := sha256.Sum256([]byte(`[0,"` + pubkey + `",1700000000,1,[],"a <3 & b > c"]`))
+	event := NostrEvent{
+		ID:        hex.EncodeToString(hash[:]),
+		Pubkey:    pubkey,
+		CreatedAt: 1700000000,
+		Kind:      1,
+		Content:   "a <3 & b > c",
+	}
+	if err := signer.SignEvent(context.Background(), &event); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestVerifyEventValid(t *testing.T) {
+	if err := VerifyEvent(signedTestEvent(t)); err != nil {
+		t.Errorf("VerifyEvent() = %v, want nil for a valid event containing <", err)
+	}
+}
+
+func TestVerifyEventTampered(t *testing.T) {
+	event := signedTestEvent(t)
+	event.Content = "a <3 & b > d"
+	if err := VerifyEvent(event); err == nil {
+		t.Error("VerifyEvent() = nil, want an error for tampered content")
+	}
+}
@@ -0,0 +1,72 @@
+package nostr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxEventSize is the largest EVENT message in bytes sent to any relay, zero means no limit
+var maxEventSize int
+
+// SetMaxEventSize sets the largest EVENT message in bytes that is sent to a relay, for relays whose
+// frame limit isn't advertised over NIP-11. It must be called before publishing starts; zero or less
+// removes the limit.
+func SetMaxEventSize(n int) {
+	maxEventSize = max(n, 0)
+}
+
+// checkEventSize returns an error when the event's EVENT message is larger than the max event size
+func checkEventSize(relayURL string, event NostrEvent) error {
+	if maxEventSize == 0 {
+		return nil
+	}
+
+	message, err := json.Marshal([]interface{}{"EVENT", event})
+	if err == nil && len(message) > maxEventSize {
+		return &RelayError{
+			Relay:  relayURL,
+			Reason: fmt.Sprintf("message is %d bytes, max_event_size is %d", len(message), maxEventSize),
+			Err:    ErrOverRelayLimit,
+		}
+	}
+	return nil
+}
+
+// FitsRelays reports whether an event of the kind with the content and tags would stay within the
+// max event size and the NIP-11 limits of every relay, once the ID, signature, static tags and any
+// proof of work nonce are added
+func FitsRelays(kind int, content, pubkey string, tags [][]string, relayURLs []string) bool {
+	probe := NostrEvent{
+		ID:        strings.Repeat("0", 64),
+		Pubkey:    pubkey,
+		CreatedAt: time.Now().Unix(),
+		Kind:      kind,
+		Tags:      eventTags(tags),
+		Content:   content,
+		Sig:       strings.Repeat("0", 128),
+	}
+	if difficulty := requiredPoW(relayURLs); difficulty > 0 {
+		probe.Tags = append(probe.Tags, []string{"nonce", strconv.FormatUint(1<<63, 10), strconv.Itoa(difficulty)})
+	}
+
+	for _, relayURL := range relayURLs {
+		if checkEventSize(relayURL, probe) != nil || checkRelayLimits(relayURL, probe) != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// allOverLimit reports whether every error is a relay skipped because the event exceeds its limits
+func allOverLimit(errs []error) bool {
+	for _, err := range errs {
+		if !errors.Is(err, ErrOverRelayLimit) {
+			return false
+		}
+	}
+	return len(errs) > 0
+}
@@ -0,0 +1,130 @@
+package nostr
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/gorilla/websocket"
+)
+
+// Filter represents a NIP-01 subscription filter
+type Filter struct {
+	Kinds   []int    `json:"kinds,omitempty"`
+	Authors []string `json:"authors,omitempty"`
+	ETags   []string `json:"#e,omitempty"`
+	PTags   []string `json:"#p,omitempty"`
+	TTags   []string `json:"#t,omitempty"`
+	Since   int64    `json:"since,omitempty"`
+	Until   int64    `json:"until,omitempty"`
+	Limit   int      `json:"limit,omitempty"`
+}
+
+// Subscribe opens a subscription on the relay and calls onEvent for every event it delivers.
+// It blocks until ctx is done, the connection fails or the relay closes the subscription.
+func Subscribe(ctx context.Context, relayURL, subID string, filter Filter, onEvent func(NostrEvent)) error {
+	return subscribe(ctx, relayURL, subID, filter, onEvent, nil)
+}
+
+// FetchEvents returns the stored events matching the filter, ending the subscription once the relay
+// signals the end of stored events
+func FetchEvents(ctx context.Context, relayURL string, filter Filter) ([]NostrEvent, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var events []NostrEvent
+	eose := false
+	err := subscribe(ctx, relayURL, "fetch", filter, func(event NostrEvent) {
+		events = append(events, event)
+	}, func() {
+		eose = true
+		cancel()
+	})
+	if eose {
+		return events, nil
+	}
+	return events, err
+}
+
+// subscribe is Subscribe with an optional callback for the end of stored events
+func subscribe(ctx context.Context, relayURL, subID string, filter Filter, onEvent func(NostrEvent), onEOSE func()) error {
+	ws, _, err := dialer.DialContext(ctx, relayURL, relayHeader(relayURL))
+	if err != nil {
+		log.Printf("Error connecting to Nostr relay: %v", err)
+		return fmt.Errorf("%w: %v", ErrDialFailed, err)
+	}
+	defer ws.Close()
+
+	// Closing the socket unblocks the read loop when ctx is done
+	stop := context.AfterFunc(ctx, func() { ws.Close() })
+	defer stop()
+	log.Printf("Connected to Nostr relay %s for subscription %s", relayURL, subID)
+
+	// Quiet subscriptions are kept alive with pings until Subscribe returns
+	done := make(chan struct{})
+	defer close(done)
+	startKeepalive(ws, relayURL, done)
+
+	reqJSON, err := json.Marshal([]interface{}{"REQ", subID, filter})
+	if err != nil {
+		return fmt.Errorf("failed to serialize subscription request: %w", err)
+	}
+
+	log.Printf("Sending subscription request to relay: %s", reqJSON)
+	traceFrame(">", relayURL, reqJSON)
+	err = ws.WriteMessage(websocket.TextMessage, reqJSON)
+	if err != nil {
+		log.Printf("Error sending subscription request: %v", err)
+		return fmt.Errorf("failed to send subscription request: %v", err)
+	}
+
+	for {
+		_, message, err := ws.ReadMessage()
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err != nil {
+			log.Printf("Error reading from relay: %v", err)
+			return fmt.Errorf("failed to read from relay: %v", err)
+		}
+		traceFrame("<", relayURL, message)
+
+		var frame []json.RawMessage
+		var label string
+		if err := json.Unmarshal(message, &frame); err != nil || len(frame) == 0 ||
+			json.Unmarshal(frame[0], &label) != nil {
+			log.Printf("Ignoring malformed relay message: %s", string(message))
+			continue
+		}
+
+		switch label {
+		case "EVENT":
+			var id string
+			var event NostrEvent
+			if len(frame) < 3 || json.Unmarshal(frame[1], &id) != nil || id != subID {
+				continue
+			}
+			if err := json.Unmarshal(frame[2], &event); err != nil {
+				log.Printf("Ignoring malformed event from relay: %v", err)
+				continue
+			}
+			// A relay could inject forged events, so only events signed by their pubkey are passed on
+			if err := VerifyEvent(event); err != nil {
+				log.Printf("Dropping event %s from %s that fails verification: %v", event.ID, relayURL, err)
+				continue
+			}
+			onEvent(event)
+		case "EOSE":
+			log.Printf("Subscription %s reached end of stored events", subID)
+			if onEOSE != nil {
+				onEOSE()
+			}
+		case "NOTICE":
+			log.Printf("Relay notice: %s", string(message))
+		case "CLOSED":
+			log.Printf("Relay closed subscription: %s", string(message))
+			return fmt.Errorf("relay closed subscription: %s", string(message))
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package nostr
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+var (
+	// dialer opens every relay WebSocket connection
+	dialer = websocket.DefaultDialer
+	// httpClient fetches relay information documents
+	httpClient = http.DefaultClient
+
+	// tlsConfig and connectTimeout are the settings the dialer was built from
+	tlsConfig      *tls.Config
+	connectTimeout time.Duration
+)
+
+// SetTLSConfig sets the TLS configuration used for wss relay connections and NIP-11 requests, e.g. to
+// trust an internal CA. It must be called before any relay is contacted; nil restores the defaults.
+func SetTLSConfig(config *tls.Config) {
+	tlsConfig = config
+	buildDialer()
+
+	if config == nil {
+		httpClient = http.DefaultClient
+		return
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = config
+	httpClient = &http.Client{Transport: transport}
+}
+
+// SetConnectTimeout bounds how long connecting to a relay may take, including the TLS and WebSocket
+// handshakes, so a dead relay fails fast. It must be called before any relay is contacted; zero or
+// less restores the default of 45 seconds.
+func SetConnectTimeout(d time.Duration) {
+	connectTimeout = max(d, 0)
+	buildDialer()
+}
+
+// buildDialer replaces the dialer with one using the configured TLS settings and connect timeout
+func buildDialer() {
+	if tlsConfig == nil && connectTimeout == 0 {
+		dialer = websocket.DefaultDialer
+		return
+	}
+
+	d := *websocket.DefaultDialer
+	d.TLSClientConfig = tlsConfig
+	if connectTimeout > 0 {
+		d.HandshakeTimeout = connectTimeout
+	}
+	dialer = &d
+}
@@ -0,0 +1,27 @@
+package nostr
+
+import (
+	"encoding/hex"
+	"log"
+)
+
+// logFrames and hexFrames control whether raw WebSocket frames are logged and how
+var logFrames, hexFrames bool
+
+// SetFrameLogging logs every raw WebSocket frame sent to or received from relays, as text or hex
+// encoded to show bytes that don't print. It is meant for debugging relay interop issues.
+func SetFrameLogging(enabled, hexEncoded bool) {
+	logFrames = enabled
+	hexFrames = hexEncoded
+}
+
+// traceFrame logs a raw frame sent (">") to or received ("<") from the relay when frame logging is on
+func traceFrame(direction, relayURL string, data []byte) {
+	switch {
+	case !logFrames:
+	case hexFrames:
+		log.Printf("Frame %s %s: %s", direction, relayURL, hex.EncodeToString(data))
+	default:
+		log.Printf("Frame %s %s: %s", direction, relayURL, data)
+	}
+}
@@ -0,0 +1,180 @@
+package nostr
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// urlRe matches http and https links in message text, stopping at the brackets Discord uses to
+// suppress embeds
+var urlRe = regexp.MustCompile(`https?://[^\s<>]+`)
+
+// trackingParams are query parameters that only serve tracking. Parameters starting with utm_ are
+// removed as well.
+var trackingParams = map[string]bool{
+	"fbclid": true, "gclid": true, "dclid": true, "msclkid": true, "yclid": true, "igshid": true,
+	"mc_cid": true, "mc_eid": true, "_hsenc": true, "_hsmi": true, "mkt_tok": true, "ref_src": true,
+	"si": true, "twclid": true, "ttclid": true, "wt_mc": true,
+}
+
+// maxUnwrappedLinks is how many different links of a message are resolved, later ones only have their
+// tracking parameters stripped
+const maxUnwrappedLinks = 5
+
+// unwrapClient resolves links without following redirects, only ever connecting to public addresses
+var unwrapClient = &http.Client{
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: dialPublicOnly}).DialContext,
+	},
+	// Only the first redirect is followed, so the Location header is read instead
+	CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// unwrapURLs resolves a single redirect of the first links in the content and strips tracking
+// parameters. The lookups run in parallel and all of them together take at most timeout. Links that
+// fail to resolve keep their original target.
+func unwrapURLs(content string, timeout time.Duration) string {
+	var links []string
+	for _, link := range urlRe.FindAllString(content, -1) {
+		trimmed, _ := trimLink(link)
+		if len(links) < maxUnwrappedLinks && !slices.Contains(links, trimmed) {
+			links = append(links, trimmed)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	targets := make([]string, len(links))
+	var wg sync.WaitGroup
+	for i, link := range links {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			targets[i] = followRedirect(ctx, link)
+		}()
+	}
+	wg.Wait()
+
+	resolved := make(map[string]string, len(links))
+	for i, link := range links {
+		resolved[link] = targets[i]
+	}
+	return urlRe.ReplaceAllStringFunc(content, func(link string) string {
+		trimmed, rest := trimLink(link)
+		if target, ok := resolved[trimmed]; ok {
+			return stripTracking(target) + rest
+		}
+		return stripTracking(trimmed) + rest
+	})
+}
+
+// trimLink splits punctuation after a link, which usually belongs to the sentence, from the link
+func trimLink(link string) (trimmed, rest string) {
+	trimmed = strings.TrimRight(link, ".,;:!?)'\"")
+	return trimmed, link[len(trimmed):]
+}
+
+// followRedirect returns the target the link redirects to, or the link itself when it doesn't
+// redirect or the lookup fails
+func followRedirect(ctx context.Context, link string) string {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, link, nil)
+	if err != nil {
+		return link
+	}
+	req.Header.Set("User-Agent", ClientName+"/"+Version)
+
+	resp, err := unwrapClient.Do(req)
+	if err != nil {
+		log.Printf("Error resolving link %s: %v", link, err)
+		return link
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 300 || resp.StatusCode >= 400 {
+		return link
+	}
+	target, err := req.URL.Parse(resp.Header.Get("Location"))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return link
+	}
+	return target.String()
+}
+
+// dialPublicOnly refuses connections to loopback, private, link-local and other non-public
+// addresses, so links posted in Discord can't make the bridge reach its own network. It runs after
+// name resolution, for every address that is tried.
+func dialPublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return err
+	}
+	if !isPublicAddr(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", ip)
+	}
+	return nil
+}
+
+// nonPublicPrefixes are ranges not covered by the netip.Addr checks that are still not reachable on
+// the internet
+var nonPublicPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"), // Carrier-grade NAT
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"), // NAT64, maps to IPv4 addresses
+}
+
+// isPublicAddr reports whether the address is a public unicast address
+func isPublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+		return false
+	}
+	for _, prefix := range nonPublicPrefixes {
+		if prefix.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// stripTracking removes known tracking parameters from the link's query, keeping it unchanged when
+// it has none
+func stripTracking(link string) string {
+	u, err := url.Parse(link)
+	if err != nil || u.RawQuery == "" {
+		return link
+	}
+
+	query := u.Query()
+	removed := false
+	for param := range query {
+		if trackingParams[strings.ToLower(param)] || strings.HasPrefix(strings.ToLower(param), "utm_") {
+			query.Del(param)
+			removed = true
+		}
+	}
+	if !removed {
+		return link
+	}
+	u.RawQuery = query.Encode()
+	return u.String()
+}
@@ -0,0 +1,107 @@
+package nostr
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIsPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"1.1.1.1":            true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false, // Cloud metadata
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"::":                 false,
+		"fe80::1":            false,
+		"fd00:ec2::254":      false,
+		"::ffff:127.0.0.1":   false,
+		"64:ff9b::a9fe:a9fe": false,
+		"224.0.0.1":          false,
+		"255.255.255.255":    false,
+	}
+	for addr, want := range tests {
+		if got := isPublicAddr(netip.MustParseAddr(addr)); got != want {
+			t.Errorf("isPublicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
+
+func TestUnwrapURLsSkipsLocalAddresses(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Redirect(w, r, "https://example.com/", http.StatusFound)
+	}))
+	defer server.Close()
+
+	content := "see " + server.URL + "/admin?utm_source=x."
+	want := "see " + server.URL + "/admin."
+	if got := unwrapURLs(content, time.Second); got != want {
+		t.Errorf("unwrapURLs() = %q, want %q", got, want)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("unwrapURLs() made %d requests to a loopback address, want none", n)
+	}
+}
+
+// roundTripFunc lets a test answer the requests of unwrapClient
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func withUnwrapTransport(t *testing.T, transport roundTripFunc) {
+	t.Helper()
+	client := *unwrapClient
+	client.Transport = transport
+	original := unwrapClient
+	unwrapClient = &client
+	t.Cleanup(func() { unwrapClient = original })
+}
+
+func TestUnwrapURLsLimit(t *testing.T) {
+	var requests atomic.Int32
+	withUnwrapTransport(t, func(req *http.Request) (*http.Response, error) {
+		requests.Add(1)
+		header := http.Header{"Location": {"https://example.org" + req.URL.Path}}
+		return &http.Response{StatusCode: http.StatusMovedPermanently, Header: header, Body: http.NoBody}, nil
+	})
+
+	links := make([]string, maxUnwrappedLinks+3)
+	for i := range links {
+		links[i] = "https://short.example/" + strings.Repeat("a", i+1) + "?fbclid=1"
+	}
+	got := unwrapURLs(strings.Join(links, " "), time.Second)
+	if n := requests.Load(); n != maxUnwrappedLinks {
+		t.Errorf("unwrapURLs() resolved %d links, want %d", n, maxUnwrappedLinks)
+	}
+	if strings.Contains(got, "fbclid") || strings.Count(got, "https://example.org/") != maxUnwrappedLinks {
+		t.Errorf("unwrapURLs() = %q, want the first %d links resolved and tracking stripped from all", got, maxUnwrappedLinks)
+	}
+}
+
+func TestUnwrapURLsDeadline(t *testing.T) {
+	withUnwrapTransport(t, func(req *http.Request) (*http.Response, error) {
+		<-req.Context().Done()
+		return nil, req.Context().Err()
+	})
+
+	content := "https://a.example/ https://b.example/ https://c.example/"
+	start := time.Now()
+	if got := unwrapURLs(content, 100*time.Millisecond); got != content {
+		t.Errorf("unwrapURLs() = %q, want the links unchanged", got)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("unwrapURLs() took %s, want all lookups to share one deadline", elapsed)
+	}
+}
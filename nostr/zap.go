@@ -0,0 +1,133 @@
+package nostr
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+const (
+	// ZapRequestKind is the NIP-57 zap request event kind
+	ZapRequestKind = 9734
+	// ZapReceiptKind is the NIP-57 zap receipt event kind
+	ZapReceiptKind = 9735
+)
+
+// bolt11AmountRe extracts the amount and multiplier from the human readable part of a BOLT11 invoice
+var bolt11AmountRe = regexp.MustCompile(`^ln[a-z]+?(\d+)([munp]?)1`)
+
+// Zap describes a parsed NIP-57 zap receipt
+type Zap struct {
+	AmountMsat int64
+	Sender     string // Pubkey of the zapper, taken from the embedded zap request
+	Recipient  string // Zapped pubkey, taken from the p tag of the zap request
+	Provider   string // Pubkey of the LNURL provider that issued the receipt
+	EventID    string // Zapped event, empty for profile zaps
+	Comment    string
+}
+
+// ParseZapReceipt extracts the amount, sender and zapped event from a kind 9735 zap receipt. The
+// embedded zap request must be validly signed and zap the same pubkey and event as the receipt.
+// Whether the provider may issue receipts for the recipient is left to the caller.
+func ParseZapReceipt(event NostrEvent) (*Zap, error) {
+	if event.Kind != ZapReceiptKind {
+		return nil, fmt.Errorf("event kind %d is not a zap receipt", event.Kind)
+	}
+
+	zap := &Zap{Provider: event.Pubkey}
+	var bolt11, description, recipient string
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "e":
+			zap.EventID = tag[1]
+		case "p":
+			recipient = tag[1]
+		case "bolt11":
+			bolt11 = tag[1]
+		case "description":
+			description = tag[1]
+		}
+	}
+
+	// The description holds the zap request signed by the sender
+	var request NostrEvent
+	if err := json.Unmarshal([]byte(description), &request); err != nil {
+		return nil, fmt.Errorf("invalid zap request in description: %w", err)
+	}
+	if request.Kind != ZapRequestKind {
+		return nil, fmt.Errorf("event kind %d in description is not a zap request", request.Kind)
+	}
+	if err := VerifyEvent(request); err != nil {
+		return nil, fmt.Errorf("invalid zap request in description: %w", err)
+	}
+
+	var requestAmount int64
+	var requestEventID string
+	for _, tag := range request.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "amount":
+			requestAmount, _ = strconv.ParseInt(tag[1], 10, 64)
+		case "p":
+			zap.Recipient = tag[1]
+		case "e":
+			requestEventID = tag[1]
+		}
+	}
+	if zap.Recipient == "" || zap.Recipient != recipient {
+		return nil, errors.New("zap receipt and zap request name different recipients")
+	}
+	if requestEventID != zap.EventID {
+		return nil, errors.New("zap receipt and zap request name different events")
+	}
+	zap.Sender = request.Pubkey
+	zap.Comment = request.Content
+
+	// The invoice is authoritative for what was actually paid
+	zap.AmountMsat = requestAmount
+	if amount, err := bolt11AmountMsat(bolt11); err == nil {
+		if requestAmount > 0 && amount != requestAmount {
+			return nil, fmt.Errorf("invoice of %d msat doesn't match the requested %d msat", amount, requestAmount)
+		}
+		zap.AmountMsat = amount
+	}
+	if zap.AmountMsat <= 0 {
+		return nil, errors.New("zap receipt has no amount")
+	}
+
+	return zap, nil
+}
+
+// bolt11AmountMsat returns the amount encoded in a BOLT11 invoice in millisatoshis
+func bolt11AmountMsat(invoice string) (int64, error) {
+	match := bolt11AmountRe.FindStringSubmatch(invoice)
+	if match == nil {
+		return 0, errors.New("invoice has no amount")
+	}
+
+	amount, err := strconv.ParseInt(match[1], 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	// Amounts are in bitcoin, scaled down by the multiplier; one bitcoin is 1e11 msat
+	switch match[2] {
+	case "":
+		return amount * 100_000_000_000, nil
+	case "m":
+		return amount * 100_000_000, nil
+	case "u":
+		return amount * 100_000, nil
+	case "n":
+		return amount * 100, nil
+	default: // "p"
+		return amount / 10, nil
+	}
+}
@@ -0,0 +1,70 @@
+package nostr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// testZapReceipt returns a receipt for a zap request of 21 sats to recipient, with the request's p
+// tag set to requested
+func testZapReceipt(t *testing.T, recipient, requested string) NostrEvent {
+	t.Helper()
+	senderKey := testPrivKey(t, "0000000000000000000000000000000000000000000000000000000000000003")
+	sender, _ := DerivePublicKey("0000000000000000000000000000000000000000000000000000000000000003")
+
+	request := NostrEvent{
+		Pubkey:    sender,
+		CreatedAt: 1700000000,
+		Kind:      ZapRequestKind,
+		Tags:      [][]string{{"p", requested}, {"e", testEventID}, {"amount", "21000"}},
+		Content:   "great <note>",
+	}
+	eventStr, _ := serializeEvent(request)
+	request.ID = ComputeEventID(eventStr)
+	request.Sig, _ = SignEventSchnorr(request.ID, senderKey)
+	description, _ := json.Marshal(request)
+
+	return NostrEvent{
+		Pubkey: "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5",
+		Kind:   ZapReceiptKind,
+		Tags: [][]string{
+			{"p", recipient},
+			{"e", testEventID},
+			{"bolt11", "lnbc210n1pjexample"},
+			{"description", string(description)},
+		},
+	}
+}
+
+func TestParseZapReceipt(t *testing.T) {
+	zap, err := ParseZapReceipt(testZapReceipt(t, testPubkey, testPubkey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zap.AmountMsat != 21000 || zap.Recipient != testPubkey || zap.EventID != testEventID || zap.Comment != "great <note>" {
+		t.Errorf("ParseZapReceipt() = %+v", zap)
+	}
+	if zap.Provider != "c6047f9441ed7d6d3045406e95c07cd85c778e4b8cef3ca7abac09b95c709ee5" {
+		t.Errorf("ParseZapReceipt() provider = %s, want the receipt's pubkey", zap.Provider)
+	}
+}
+
+func TestParseZapReceiptForgedRequest(t *testing.T) {
+	receipt := testZapReceipt(t, testPubkey, testPubkey)
+	for i, tag := range receipt.Tags {
+		if tag[0] == "description" {
+			receipt.Tags[i][1] = strings.Replace(tag[1], "great", "awful", 1)
+		}
+	}
+	if _, err := ParseZapReceipt(receipt); err == nil {
+		t.Error("ParseZapReceipt() = nil, want an error for a tampered zap request")
+	}
+}
+
+func TestParseZapReceiptOtherRecipient(t *testing.T) {
+	other := strings.Repeat("b", 64)
+	if _, err := ParseZapReceipt(testZapReceipt(t, testPubkey, other)); err == nil {
+		t.Error("ParseZapReceipt() = nil, want an error when the request zaps another pubkey")
+	}
+}
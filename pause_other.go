@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "ndmBridge/bridge"
+
+// handlePauseSignal does nothing on platforms without SIGUSR1
+func handlePauseSignal(b *bridge.Bridge) {}
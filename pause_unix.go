@@ -0,0 +1,25 @@
+//go:build unix
+
+package main
+
+import (
+	"ndmBridge/bridge"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// handlePauseSignal toggles pausing the bridge on SIGUSR1
+func handlePauseSignal(b *bridge.Bridge) {
+	usr1 := make(chan os.Signal, 1)
+	signal.Notify(usr1, syscall.SIGUSR1)
+	go func() {
+		for range usr1 {
+			if b.Paused() {
+				b.Resume()
+			} else {
+				b.Pause()
+			}
+		}
+	}()
+}
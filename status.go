@@ -0,0 +1,48 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"ndmBridge/bridge"
+	"ndmBridge/nostr"
+	"ndmBridge/utils"
+	"time"
+)
+
+// printStatus queries the running bridge over its control socket and prints its status
+func printStatus(config *utils.Config) error {
+	if config.Bridge.ControlSocket == "" {
+		return errors.New("bridge.control_socket is not set in the config")
+	}
+
+	status, err := bridge.QueryStatus(config.Bridge.ControlSocket)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Uptime:           %s\n", time.Since(status.StartedAt).Round(time.Second))
+	fmt.Printf("Messages bridged: %d\n", status.MessagesBridged)
+	fmt.Printf("Paused:           %t\n", status.Paused)
+	fmt.Printf("Queue depth:      %d\n", status.QueueDepth)
+	fmt.Println("Relays:")
+	if len(status.Relays) == 0 {
+		fmt.Println("  none published to yet")
+	}
+	for _, relay := range status.Relays {
+		state := "disconnected"
+		if relay.Connected {
+			state = "connected"
+		}
+		fmt.Printf("  %s: %s, %d published, %d failed\n", relay.URL, state, relay.Published, relay.Failed)
+		switch relay.Breaker {
+		case nostr.BreakerOpen:
+			fmt.Printf("    circuit open after %d consecutive failures, skipped until %s\n", relay.ConsecutiveFailures, relay.BreakerOpenUntil.Format(time.RFC3339))
+		case nostr.BreakerHalfOpen:
+			fmt.Printf("    circuit half-open after %d consecutive failures, probing the relay\n", relay.ConsecutiveFailures)
+		}
+		if relay.LastError != "" {
+			fmt.Printf("    last error at %s: %s\n", relay.LastErrorAt.Format(time.RFC3339), relay.LastError)
+		}
+	}
+	return nil
+}
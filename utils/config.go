@@ -1,43 +1,799 @@
 package utils
 
 import (
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
 
 	"gopkg.in/yaml.v2"
 )
 
 // Config structure to hold the data from config.yml
 type Config struct {
+	// Include lists further config files loaded after this one, relative to its directory
+	Include []string `yaml:"include"`
+
 	Discord struct {
-		Token     string `yaml:"token"`
-		ChannelID string `yaml:"channel_id"`
+		Token           string          `yaml:"token"`
+		ChannelID       string          `yaml:"channel_id"`
+		Channels        []ChannelConfig `yaml:"channels"`
+		SuccessReaction string          `yaml:"success_reaction"`
+		FailureReaction string          `yaml:"failure_reaction"`
+		PostPermalink   bool            `yaml:"post_permalink"`
+		ShardID         int             `yaml:"shard_id"`
+		ShardCount      int             `yaml:"shard_count"`
 	} `yaml:"discord"`
 	Nostr struct {
-		Pubkey   string `yaml:"pubkey"`
-		PrivKey  string `yaml:"privkey"`
-		RelayURL string `yaml:"relay_url"`
+		Pubkey             string               `yaml:"pubkey"`
+		PrivKey            string               `yaml:"privkey"`
+		PrivKeyFile        string               `yaml:"privkey_file"`
+		RelayURL           string               `yaml:"relay_url"`
+		Relays             []string             `yaml:"relays"`
+		StaticTags         [][]string           `yaml:"static_tags"`
+		DisableClientTag   bool                 `yaml:"disable_client_tag"`
+		MonotonicCreatedAt bool                 `yaml:"monotonic_created_at"`
+		LogFrames          string               `yaml:"log_frames"`
+		BunkerURL          string               `yaml:"bunker_url"`
+		BunkerClientKey    string               `yaml:"bunker_client_key"`
+		Expiration         time.Duration        `yaml:"expiration"`
+		AuthRelays         []string             `yaml:"auth_relays"`
+		RelayLimits        string               `yaml:"relay_limits"`
+		RelayTimeout       time.Duration        `yaml:"relay_timeout"`
+		RelayPoW           map[string]int       `yaml:"relay_pow"`
+		KindRelays         map[int][]string     `yaml:"kind_relays"`
+		AuthorTags         bool                 `yaml:"author_tags"`
+		CAFile             string               `yaml:"ca_file"`
+		InsecureSkipTLS    bool                 `yaml:"insecure_skip_tls_verify"`
+		RelayAuth          map[string]RelayAuth `yaml:"relay_auth"`
+		MentionPubkeys     map[string]string    `yaml:"mention_pubkeys"`
+		AuthorKeys         map[string]string    `yaml:"author_keys"`
+		AuthorSeed         string               `yaml:"author_seed"`
+		PublishQuorum      int                  `yaml:"publish_quorum"`
+		PingInterval       time.Duration        `yaml:"ping_interval"`
+		MaxEventSize       int                  `yaml:"max_event_size"`
+		ConnectTimeout     time.Duration        `yaml:"connect_timeout"`
+		Retry              struct {
+			Attempts         int           `yaml:"attempts"`
+			Backoff          time.Duration `yaml:"backoff"`
+			RetryableReasons []string      `yaml:"retryable_reasons"`
+		} `yaml:"retry"`
+		CircuitBreaker struct {
+			Failures    int           `yaml:"failures"`
+			Cooldown    time.Duration `yaml:"cooldown"`
+			MaxCooldown time.Duration `yaml:"max_cooldown"`
+		} `yaml:"circuit_breaker"`
+		StartupTest struct {
+			Relay  string `yaml:"relay"`
+			Delete bool   `yaml:"delete"`
+		} `yaml:"startup_test"`
 	} `yaml:"nostr"`
+	Bridge struct {
+		ShutdownTimeout time.Duration `yaml:"shutdown_timeout"`
+		MaxInFlight     int           `yaml:"max_in_flight"`
+		QueueHighWater  int           `yaml:"queue_high_water"`
+		LogFile         string        `yaml:"log_file"`
+		LogStderr       bool          `yaml:"log_stderr"`
+		LogFormat       string        `yaml:"log_format"`
+		EventMapFile    string        `yaml:"event_map_file"`
+		ControlSocket   string        `yaml:"control_socket"`
+		WebhookURL      string        `yaml:"webhook_url"`
+		APIAddr         string        `yaml:"api_addr"`
+	} `yaml:"bridge"`
+	Catchup struct {
+		Enabled bool `yaml:"enabled"`
+		Limit   int  `yaml:"limit"`
+	} `yaml:"catchup"`
+	Content struct {
+		StripInvisible       bool          `yaml:"strip_invisible"`
+		SplitLength          int           `yaml:"split_length"`
+		CodeBlocks           string        `yaml:"code_blocks"`
+		MarkDeletedReplies   bool          `yaml:"mark_deleted_replies"`
+		MinContentLength     int           `yaml:"min_content_length"`
+		SkipSilent           bool          `yaml:"skip_silent"`
+		SkipSuppressedEmbeds bool          `yaml:"skip_suppressed_embeds"`
+		MaxMentions          int           `yaml:"max_mentions"`
+		CaptionWindow        time.Duration `yaml:"caption_window"`
+		BridgeAttachments    *bool         `yaml:"bridge_attachments"`
+		AttachmentPrefix     string        `yaml:"attachment_prefix"`
+		MaxAttachments       int           `yaml:"max_attachments"`
+		UnwrapURLs           bool          `yaml:"unwrap_urls"`
+		UnwrapTimeout        time.Duration `yaml:"unwrap_timeout"`
+		Oversize             string        `yaml:"oversize"`
+		BlockedPatterns      []string      `yaml:"blocked_patterns"`
+		BlockedAction        string        `yaml:"blocked_action"`
+		BlockedDomains       []string      `yaml:"blocked_domains"`
+		BlockedDomainsInText bool          `yaml:"blocked_domains_in_text"`
+		Mentions             string        `yaml:"mentions"`
+		MentionCacheTTL      time.Duration `yaml:"mention_cache_ttl"`
+		AttachmentSeparator  string        `yaml:"attachment_separator"`
+	} `yaml:"content"`
+	Digest struct {
+		Enabled bool   `yaml:"enabled"`
+		Time    string `yaml:"time"`
+		Byline  string `yaml:"byline"`
+	} `yaml:"digest"`
+	Reverse struct {
+		Enabled      bool     `yaml:"enabled"`
+		Zaps         bool     `yaml:"zaps"`
+		ZapProviders []string `yaml:"zap_providers"`
+		Template     string   `yaml:"template"`
+		Embed        bool     `yaml:"embed"`
+		Filter       struct {
+			Kinds   []int         `yaml:"kinds"`
+			Authors []string      `yaml:"authors"`
+			E       []string      `yaml:"e"`
+			P       []string      `yaml:"p"`
+			T       []string      `yaml:"t"`
+			Since   time.Duration `yaml:"since"`
+		} `yaml:"filter"`
+	} `yaml:"reverse"`
+	Reactions struct {
+		Enabled bool              `yaml:"enabled"`
+		Map     map[string]string `yaml:"map"`
+	} `yaml:"reactions"`
+	Pins struct {
+		Enabled bool `yaml:"enabled"`
+	} `yaml:"pins"`
+	Voice struct {
+		Channels []string `yaml:"channels"`
+	} `yaml:"voice"`
+	Banner struct {
+		Nostr   string `yaml:"nostr"`
+		Discord string `yaml:"discord"`
+	} `yaml:"banner"`
+	Profile struct {
+		TopicChannel string        `yaml:"topic_channel"`
+		Interval     time.Duration `yaml:"interval"`
+	} `yaml:"profile"`
+	Edits struct {
+		Strategy string `yaml:"strategy"`
+	} `yaml:"edits"`
+	Moderation struct {
+		Emoji   string        `yaml:"emoji"`
+		Roles   []string      `yaml:"roles"`
+		Users   []string      `yaml:"users"`
+		Timeout time.Duration `yaml:"timeout"`
+	} `yaml:"moderation"`
+	Schedule struct {
+		Delay time.Duration `yaml:"delay"`
+		Start string        `yaml:"start"`
+		End   string        `yaml:"end"`
+	} `yaml:"schedule"`
+}
+
+// RelayAuth holds HTTP basic auth credentials for a relay behind an authenticating proxy
+type RelayAuth struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+}
+
+// ChannelConfig holds the settings of a watched Discord channel
+type ChannelConfig struct {
+	ID                string   `yaml:"id"`
+	RequiredRoleID    string   `yaml:"required_role_id"`
+	Prefix            string   `yaml:"prefix"`
+	Suffix            string   `yaml:"suffix"`
+	MinContentLength  int      `yaml:"min_content_length"`
+	BridgeAttachments *bool    `yaml:"bridge_attachments"`
+	AutoTags          []string `yaml:"auto_tags"`
+}
+
+// MinContentLength returns the minimum prepared content length for messages of the channel
+func (c *Config) MinContentLength(channel *ChannelConfig) int {
+	if channel != nil && channel.MinContentLength > 0 {
+		return channel.MinContentLength
+	}
+	return c.Content.MinContentLength
+}
+
+const (
+	// DefaultShutdownTimeout is how long shutdown waits for in-flight events when not configured
+	DefaultShutdownTimeout = 5 * time.Second
+	// DefaultRelayTimeout is how long a publish waits for each relay when not configured
+	DefaultRelayTimeout = 10 * time.Second
+	// DefaultRetryBackoff is the wait before the first retry of a failed relay when not configured
+	DefaultRetryBackoff = 2 * time.Second
+	// DefaultMaxInFlight is how many events may be published at once when not configured
+	DefaultMaxInFlight = 8
+	// DefaultDigestTime is the local time the daily digest is published when not configured
+	DefaultDigestTime = "00:00"
+	// DefaultReverseTemplate formats Nostr replies posted to Discord when reverse.template isn't set
+	DefaultReverseTemplate = "**{{.Author}}** replied on Nostr:\n{{.Content}}"
+	// DefaultBreakerCooldown is how long a relay is first skipped after its circuit breaker opens when not configured
+	DefaultBreakerCooldown = time.Minute
+	// DefaultBreakerMaxCooldown caps the doubling circuit breaker cooldown when not configured
+	DefaultBreakerMaxCooldown = 30 * time.Minute
+	// DefaultProfileInterval is how often the channel topic is synced to the profile when not configured
+	DefaultProfileInterval = time.Hour
+	// DefaultUnwrapTimeout is how long resolving the redirects of a message's links may take when not configured
+	DefaultUnwrapTimeout = 3 * time.Second
+	// DefaultMentionCacheTTL is how long resolved mention names are cached when not configured
+	DefaultMentionCacheTTL = time.Hour
+	// DefaultModerationTimeout is how long a message waits for approval when not configured
+	DefaultModerationTimeout = 24 * time.Hour
+	// MaxCatchupLimit is the most messages Discord returns in one history request
+	MaxCatchupLimit = 100
+)
+
+// Values of content.code_blocks
+const (
+	// CodeBlocksPreserve keeps code blocks as Discord sent them, with their ``` fences
+	CodeBlocksPreserve = "preserve"
+	// CodeBlocksStrip removes the fences and keeps the code
+	CodeBlocksStrip = "strip"
+	// CodeBlocksIndent replaces the fences with a four space indent
+	CodeBlocksIndent = "indent"
+)
+
+// Values of nostr.relay_limits
+const (
+	// RelayLimitsWarn logs a warning when an event exceeds a relay's NIP-11 limits but still sends it
+	RelayLimitsWarn = "warn"
+	// RelayLimitsSkip doesn't send events to relays whose NIP-11 limits they exceed
+	RelayLimitsSkip = "skip"
+)
+
+// Values of nostr.log_frames
+const (
+	// LogFramesText logs raw relay WebSocket frames as text
+	LogFramesText = "text"
+	// LogFramesHex logs raw relay WebSocket frames hex encoded
+	LogFramesHex = "hex"
+)
+
+// Values of edits.strategy
+const (
+	// EditsRepost replaces the note of an edited message with a new one and requests deletion of the old one
+	EditsRepost = "repost"
+	// EditsFollowUp publishes the updated text as a reply to the note
+	EditsFollowUp = "followup"
+	// EditsComment publishes the updated text as a NIP-22 comment on the note
+	EditsComment = "comment"
+)
+
+// Values of content.oversize
+const (
+	// OversizeSkip doesn't bridge messages whose note is too large for the relays
+	OversizeSkip = "skip"
+	// OversizeSplit splits oversized content into a chain of notes small enough for the relays
+	OversizeSplit = "split"
+	// OversizeTruncate cuts oversized content to fit the relays
+	OversizeTruncate = "truncate"
+)
+
+// Values of content.blocked_action
+const (
+	// BlockedSkip doesn't bridge messages matching a blocked pattern
+	BlockedSkip = "skip"
+	// BlockedRedact replaces the matches of blocked patterns and bridges the rest of the message
+	BlockedRedact = "redact"
+)
+
+// Values of content.mentions
+const (
+	// MentionsStrip removes user, role and channel mentions from notes
+	MentionsStrip = "strip"
+	// MentionsNames replaces mentions with the names of the users, roles and channels
+	MentionsNames = "names"
+)
+
+// Values of digest.byline
+const (
+	// BylineMessage starts every message in a digest with its author's name
+	BylineMessage = "message"
+	// BylineBurst names the author once for consecutive messages of the same author
+	BylineBurst = "burst"
+	// BylineNever leaves the author names out
+	BylineNever = "never"
+)
+
+// Values of bridge.log_format
+const (
+	// LogFormatText writes the default human readable log lines
+	LogFormatText = "text"
+	// LogFormatJSON writes one JSON object per log line
+	LogFormatJSON = "json"
+)
+
+// LoadConfig reads and parses the configuration files. Settings in later files, and in files
+// included by a file, override the ones loaded before them.
+func LoadConfig(filenames ...string) (*Config, error) {
+	var config Config
+	loaded := make(map[string]bool)
+	for _, filename := range filenames {
+		if err := config.load(filename, loaded); err != nil {
+			return nil, err
+		}
+	}
+	config.Include = nil
+
+	if config.Nostr.PrivKeyFile != "" {
+		if config.Nostr.PrivKey != "" {
+			return nil, fmt.Errorf("nostr.privkey and nostr.privkey_file are mutually exclusive")
+		}
+		privKey, err := readPrivKeyFile(config.Nostr.PrivKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Nostr.PrivKey = privKey
+	}
+
+	if err := config.Prepare(); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
 }
 
-// loadConfig reads and parses the configuration file
-func LoadConfig(filename string) (*Config, error) {
+// load merges a config file and the files it includes into c, skipping files already loaded
+func (c *Config) load(filename string, loaded map[string]bool) error {
+	path, err := filepath.Abs(filename)
+	if err != nil {
+		return fmt.Errorf("cannot resolve config file path: %w", err)
+	}
+	if loaded[path] {
+		return fmt.Errorf("config file %s is included more than once", filename)
+	}
+	loaded[path] = true
+
 	data, err := os.ReadFile(filename)
 	if err != nil {
-		return nil, fmt.Errorf("cannot read config file: %w", err)
+		return fmt.Errorf("cannot read config file: %w", err)
 	}
 
-	var config Config
-	err = yaml.Unmarshal(data, &config)
+	// Strict mode turns misspelled keys into errors instead of silently leaving settings empty
+	c.Include = nil
+	privKeyFile := c.Nostr.PrivKeyFile
+	c.Nostr.PrivKeyFile = ""
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return fmt.Errorf("cannot unmarshal config data in %s: %w", filename, describeYAMLError(err))
+	}
+
+	// Like includes, the key file is relative to the config file that names it
+	switch {
+	case c.Nostr.PrivKeyFile == "":
+		c.Nostr.PrivKeyFile = privKeyFile
+	case !filepath.IsAbs(c.Nostr.PrivKeyFile):
+		c.Nostr.PrivKeyFile = filepath.Join(filepath.Dir(filename), c.Nostr.PrivKeyFile)
+	}
+
+	for _, include := range c.Include {
+		if !filepath.IsAbs(include) {
+			include = filepath.Join(filepath.Dir(filename), include)
+		}
+		if err := c.load(include, loaded); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readPrivKeyFile reads the hex private key from the file. The file must not be accessible by
+// group or others, like an SSH key.
+func readPrivKeyFile(filename string) (string, error) {
+	info, err := os.Stat(filename)
 	if err != nil {
-		return nil, fmt.Errorf("cannot unmarshal config data: %w", err)
+		return "", fmt.Errorf("cannot read nostr.privkey_file: %w", err)
+	}
+	// Windows doesn't report meaningful permission bits
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("nostr.privkey_file %s is accessible by other users (mode %04o), restrict it to mode 0600", filename, info.Mode().Perm())
 	}
 
-	// Validate that necessary fields are not empty
-	if config.Discord.Token == "" || config.Discord.ChannelID == "" ||
-		config.Nostr.Pubkey == "" || config.Nostr.PrivKey == "" || config.Nostr.RelayURL == "" {
-		return nil, fmt.Errorf("all fields in config.yml must be provided")
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return "", fmt.Errorf("cannot read nostr.privkey_file: %w", err)
+	}
+	privKey := strings.TrimSpace(string(data))
+	if privKey == "" {
+		return "", fmt.Errorf("nostr.privkey_file %s is empty", filename)
 	}
+	return privKey, nil
+}
 
-	return &config, nil
-}
\ No newline at end of file
+// Prepare merges shorthand fields, applies defaults and validates the configuration.
+// LoadConfig calls it; calling it again on a prepared config is harmless.
+func (c *Config) Prepare() error {
+	// Merge the single relay_url into the relay list and drop duplicates
+	relays := c.Nostr.Relays
+	if c.Nostr.RelayURL != "" {
+		relays = append([]string{c.Nostr.RelayURL}, relays...)
+	}
+	var err error
+	c.Nostr.Relays, err = normalizeRelayURLs(relays)
+	if err != nil {
+		return err
+	}
+	c.Nostr.AuthRelays, err = normalizeRelayURLs(c.Nostr.AuthRelays)
+	if err != nil {
+		return err
+	}
+	for _, relay := range c.Nostr.AuthRelays {
+		if !slices.Contains(c.Nostr.Relays, relay) {
+			return fmt.Errorf("auth relay %s is not one of the configured relays", relay)
+		}
+	}
+	for kind, kindRelays := range c.Nostr.KindRelays {
+		normalized, err := normalizeRelayURLs(kindRelays)
+		if err != nil {
+			return err
+		}
+		if len(normalized) == 0 {
+			return fmt.Errorf("kind_relays entry for kind %d must list at least one relay", kind)
+		}
+		for _, relay := range normalized {
+			if !slices.Contains(c.Nostr.Relays, relay) {
+				return fmt.Errorf("kind_relays relay %s for kind %d is not one of the configured relays", relay, kind)
+			}
+		}
+		c.Nostr.KindRelays[kind] = normalized
+	}
+	relayPoW := make(map[string]int, len(c.Nostr.RelayPoW))
+	for relay, difficulty := range c.Nostr.RelayPoW {
+		normalized, err := normalizeRelayURLs([]string{relay})
+		if err != nil {
+			return err
+		}
+		if len(normalized) == 0 || !slices.Contains(c.Nostr.Relays, normalized[0]) {
+			return fmt.Errorf("relay_pow relay %s is not one of the configured relays", relay)
+		}
+		if difficulty < 0 {
+			return fmt.Errorf("relay_pow difficulty for %s must not be negative", relay)
+		}
+		relayPoW[normalized[0]] = difficulty
+	}
+	c.Nostr.RelayPoW = relayPoW
+	relayAuth := make(map[string]RelayAuth, len(c.Nostr.RelayAuth))
+	for relay, auth := range c.Nostr.RelayAuth {
+		normalized, err := normalizeRelayURLs([]string{relay})
+		if err != nil {
+			return err
+		}
+		if len(normalized) == 0 || !slices.Contains(c.Nostr.Relays, normalized[0]) {
+			return fmt.Errorf("relay_auth relay %s is not one of the configured relays", relay)
+		}
+		relayAuth[normalized[0]] = auth
+	}
+	c.Nostr.RelayAuth = relayAuth
+	testRelay, err := normalizeRelayURLs([]string{c.Nostr.StartupTest.Relay})
+	if err != nil {
+		return err
+	}
+	c.Nostr.StartupTest.Relay = ""
+	if len(testRelay) > 0 {
+		c.Nostr.StartupTest.Relay = testRelay[0]
+	}
+	for userID, pubkey := range c.Nostr.MentionPubkeys {
+		if _, err := hex.DecodeString(pubkey); err != nil || len(pubkey) != 64 {
+			return fmt.Errorf("mention_pubkeys entry for Discord user %s must be a 64 character hex pubkey", userID)
+		}
+	}
+
+	// The single channel_id is shorthand for a channel entry without extra settings
+	if c.Discord.ChannelID != "" && c.Channel(c.Discord.ChannelID) == nil {
+		c.Discord.Channels = append([]ChannelConfig{{ID: c.Discord.ChannelID}}, c.Discord.Channels...)
+	}
+	for i, channel := range c.Discord.Channels {
+		if channel.ID == "" {
+			return fmt.Errorf("every entry in discord.channels must have an id")
+		}
+		// Hashtags are written without the # and in lower case, like the forum tags
+		for j, hashtag := range channel.AutoTags {
+			hashtag = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(hashtag), "#"))
+			if hashtag == "" || strings.ContainsAny(hashtag, " \t\n") {
+				return fmt.Errorf("auto_tags of channel %s must be single words", channel.ID)
+			}
+			c.Discord.Channels[i].AutoTags[j] = hashtag
+		}
+	}
+
+	// Validate that necessary fields are not empty. The private key is optional with a remote signer.
+	var missing []string
+	if c.Discord.Token == "" {
+		missing = append(missing, "discord.token")
+	}
+	if len(c.Discord.Channels) == 0 {
+		missing = append(missing, "discord.channel_id or discord.channels")
+	}
+	if c.Nostr.Pubkey == "" {
+		missing = append(missing, "nostr.pubkey")
+	}
+	if c.Nostr.PrivKey == "" && c.Nostr.BunkerURL == "" {
+		missing = append(missing, "nostr.privkey, nostr.privkey_file or nostr.bunker_url")
+	}
+	if len(c.Nostr.Relays) == 0 {
+		missing = append(missing, "nostr.relay_url or nostr.relays")
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required config settings: %s", strings.Join(missing, ", "))
+	}
+
+	for _, tag := range c.Nostr.StaticTags {
+		if len(tag) < 2 || tag[0] == "" {
+			return fmt.Errorf("static tag %v must have a name and at least one value", tag)
+		}
+	}
+
+	// Apply defaults for optional fields
+	if c.Bridge.ShutdownTimeout <= 0 {
+		c.Bridge.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	if c.Nostr.RelayTimeout <= 0 {
+		c.Nostr.RelayTimeout = DefaultRelayTimeout
+	}
+	if c.Nostr.PublishQuorum <= 0 {
+		c.Nostr.PublishQuorum = 1
+	}
+	if c.Nostr.PublishQuorum > len(c.Nostr.Relays) {
+		return fmt.Errorf("nostr.publish_quorum of %d is more than the %d configured relays", c.Nostr.PublishQuorum, len(c.Nostr.Relays))
+	}
+	if c.Nostr.Retry.Attempts <= 0 {
+		c.Nostr.Retry.Attempts = 1
+	}
+	if c.Nostr.Retry.Backoff <= 0 {
+		c.Nostr.Retry.Backoff = DefaultRetryBackoff
+	}
+	if c.Nostr.Retry.RetryableReasons == nil {
+		c.Nostr.Retry.RetryableReasons = []string{"rate-limited:"}
+	}
+	if c.Bridge.MaxInFlight <= 0 {
+		c.Bridge.MaxInFlight = DefaultMaxInFlight
+	}
+	if c.Catchup.Limit <= 0 || c.Catchup.Limit > MaxCatchupLimit {
+		c.Catchup.Limit = MaxCatchupLimit
+	}
+	if c.Digest.Time == "" {
+		c.Digest.Time = DefaultDigestTime
+	}
+	if c.Digest.Byline == "" {
+		c.Digest.Byline = BylineMessage
+	}
+	if err := c.prepareReverseFilter(); err != nil {
+		return err
+	}
+	if c.Reverse.Template == "" {
+		c.Reverse.Template = DefaultReverseTemplate
+	}
+	if _, err := template.New("reply").Parse(c.Reverse.Template); err != nil {
+		return fmt.Errorf("reverse.template is not a valid template: %w", err)
+	}
+	if c.Bridge.WebhookURL != "" {
+		if u, err := url.Parse(c.Bridge.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("bridge.webhook_url must be an http or https URL")
+		}
+	}
+	if c.Bridge.APIAddr != "" {
+		if _, _, err := net.SplitHostPort(c.Bridge.APIAddr); err != nil {
+			return fmt.Errorf("bridge.api_addr must be a host:port address such as 127.0.0.1:8090: %w", err)
+		}
+	}
+	if c.Bridge.LogFormat == "" {
+		c.Bridge.LogFormat = LogFormatText
+	}
+	for _, pattern := range c.Content.BlockedPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("content.blocked_patterns entry %q is not a valid regular expression: %w", pattern, err)
+		}
+	}
+	for i, domain := range c.Content.BlockedDomains {
+		// Subdomains are always blocked too, so a leading wildcard adds nothing
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), "*.")
+		if domain == "" || strings.ContainsAny(domain, "/:") {
+			return fmt.Errorf("content.blocked_domains entry %q must be a domain name such as example.com", c.Content.BlockedDomains[i])
+		}
+		c.Content.BlockedDomains[i] = domain
+	}
+	if c.Content.BlockedAction == "" {
+		c.Content.BlockedAction = BlockedSkip
+	}
+	if c.Nostr.CircuitBreaker.Cooldown <= 0 {
+		c.Nostr.CircuitBreaker.Cooldown = DefaultBreakerCooldown
+	}
+	if c.Nostr.CircuitBreaker.MaxCooldown <= 0 {
+		c.Nostr.CircuitBreaker.MaxCooldown = DefaultBreakerMaxCooldown
+	}
+	if c.Profile.Interval <= 0 {
+		c.Profile.Interval = DefaultProfileInterval
+	}
+	if c.Moderation.Timeout <= 0 {
+		c.Moderation.Timeout = DefaultModerationTimeout
+	}
+	if c.Content.UnwrapTimeout <= 0 {
+		c.Content.UnwrapTimeout = DefaultUnwrapTimeout
+	}
+	if c.Content.Mentions == "" {
+		c.Content.Mentions = MentionsStrip
+	}
+	if c.Content.MentionCacheTTL <= 0 {
+		c.Content.MentionCacheTTL = DefaultMentionCacheTTL
+	}
+	if c.Content.Oversize == "" {
+		c.Content.Oversize = OversizeSkip
+	}
+	if c.Content.CodeBlocks == "" {
+		c.Content.CodeBlocks = CodeBlocksPreserve
+	}
+	if _, err := time.Parse("15:04", c.Digest.Time); err != nil {
+		return fmt.Errorf("digest time must be in HH:MM format: %w", err)
+	}
+	if (c.Schedule.Start == "") != (c.Schedule.End == "") {
+		return fmt.Errorf("schedule.start and schedule.end must be set together")
+	}
+	for _, clock := range []string{c.Schedule.Start, c.Schedule.End} {
+		if _, err := time.Parse("15:04", clock); clock != "" && err != nil {
+			return fmt.Errorf("schedule posting hours must be in HH:MM format: %w", err)
+		}
+	}
+
+	return c.validate()
+}
+
+// prepareReverseFilter validates the reverse bridge filter and normalizes its hashtags like auto_tags
+func (c *Config) prepareReverseFilter() error {
+	filter := &c.Reverse.Filter
+	for _, kind := range filter.Kinds {
+		if kind < 0 {
+			return fmt.Errorf("reverse.filter.kinds cannot contain negative kinds")
+		}
+	}
+	for _, list := range []struct {
+		name   string
+		values []string
+	}{{"authors", filter.Authors}, {"e", filter.E}, {"p", filter.P}} {
+		for _, value := range list.values {
+			if _, err := hex.DecodeString(value); err != nil || len(value) != 64 {
+				return fmt.Errorf("reverse.filter.%s entry %q must be 64 character hex", list.name, value)
+			}
+		}
+	}
+	for i, hashtag := range filter.T {
+		filter.T[i] = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(hashtag), "#"))
+		if filter.T[i] == "" {
+			return fmt.Errorf("reverse.filter.t cannot contain empty hashtags")
+		}
+	}
+	if filter.Since < 0 {
+		return fmt.Errorf("reverse.filter.since cannot be negative")
+	}
+	return nil
+}
+
+// RelaysForKind returns the relays events of the given kind are published to. Kinds without a
+// kind_relays entry go to every configured relay.
+func (c *Config) RelaysForKind(kind int) []string {
+	if relays, ok := c.Nostr.KindRelays[kind]; ok {
+		return relays
+	}
+	return c.Nostr.Relays
+}
+
+// BridgeAttachments reports whether attachment URLs of the channel's messages are added to notes.
+// The channel setting overrides content.bridge_attachments, and both default to true.
+func (c *Config) BridgeAttachments(channel *ChannelConfig) bool {
+	if channel != nil && channel.BridgeAttachments != nil {
+		return *channel.BridgeAttachments
+	}
+	return c.Content.BridgeAttachments == nil || *c.Content.BridgeAttachments
+}
+
+// Channel returns the settings of the watched channel with the given ID, or nil if it isn't watched
+func (c *Config) Channel(id string) *ChannelConfig {
+	for i := range c.Discord.Channels {
+		if c.Discord.Channels[i].ID == id {
+			return &c.Discord.Channels[i]
+		}
+	}
+	return nil
+}
+
+// validate returns an error for option combinations that cannot work together
+func (c *Config) validate() error {
+	switch {
+	case c.Catchup.Enabled && c.Bridge.EventMapFile == "":
+		return fmt.Errorf("catchup requires bridge.event_map_file to avoid double-posting")
+	case c.Catchup.Enabled && c.Digest.Enabled:
+		return fmt.Errorf("catchup cannot be combined with digest mode because digested messages are not recorded in the event map")
+	case c.Nostr.PrivKey != "" && c.Nostr.BunkerURL != "":
+		return fmt.Errorf("nostr.privkey and nostr.bunker_url are mutually exclusive, remove the private key when using a remote signer")
+	case c.Discord.ShardCount < 0 || c.Discord.ShardID < 0:
+		return fmt.Errorf("discord.shard_id and discord.shard_count cannot be negative")
+	case c.Discord.ShardID > 0 && c.Discord.ShardID >= c.Discord.ShardCount:
+		return fmt.Errorf("discord.shard_id must be less than discord.shard_count")
+	case c.Nostr.CircuitBreaker.Failures < 0:
+		return fmt.Errorf("nostr.circuit_breaker.failures cannot be negative")
+	case c.Nostr.CircuitBreaker.MaxCooldown < c.Nostr.CircuitBreaker.Cooldown:
+		return fmt.Errorf("nostr.circuit_breaker.max_cooldown must not be shorter than the cooldown")
+	case c.Content.MaxAttachments < 0:
+		return fmt.Errorf("content.max_attachments cannot be negative")
+	case c.Moderation.Emoji != "" && len(c.Moderation.Roles) == 0 && len(c.Moderation.Users) == 0:
+		return fmt.Errorf("moderation.emoji requires moderation.roles or moderation.users to name the moderators")
+	case c.Schedule.Delay < 0:
+		return fmt.Errorf("schedule.delay cannot be negative")
+	case c.Schedule.Start != "" && c.Schedule.Start == c.Schedule.End:
+		return fmt.Errorf("schedule.start and schedule.end cannot be the same time")
+	case c.Reverse.Zaps && !c.Reverse.Enabled:
+		return fmt.Errorf("reverse.zaps requires reverse.enabled")
+	case c.Reverse.Zaps && len(c.Reverse.ZapProviders) == 0:
+		return fmt.Errorf("reverse.zaps requires reverse.zap_providers to name the providers trusted to issue zap receipts")
+	case c.Content.BlockedAction != BlockedSkip && c.Content.BlockedAction != BlockedRedact:
+		return fmt.Errorf("content.blocked_action must be %q or %q", BlockedSkip, BlockedRedact)
+	case c.Content.Mentions != MentionsStrip && c.Content.Mentions != MentionsNames:
+		return fmt.Errorf("content.mentions must be %q or %q", MentionsStrip, MentionsNames)
+	case c.Content.Oversize != OversizeSkip && c.Content.Oversize != OversizeSplit && c.Content.Oversize != OversizeTruncate:
+		return fmt.Errorf("content.oversize must be %q, %q or %q", OversizeSkip, OversizeSplit, OversizeTruncate)
+	case c.Digest.Byline != BylineMessage && c.Digest.Byline != BylineBurst && c.Digest.Byline != BylineNever:
+		return fmt.Errorf("digest.byline must be %q, %q or %q", BylineMessage, BylineBurst, BylineNever)
+	case c.Bridge.LogFormat != LogFormatText && c.Bridge.LogFormat != LogFormatJSON:
+		return fmt.Errorf("bridge.log_format must be %q or %q", LogFormatText, LogFormatJSON)
+	case c.Nostr.LogFrames != "" && c.Nostr.LogFrames != LogFramesText && c.Nostr.LogFrames != LogFramesHex:
+		return fmt.Errorf("nostr.log_frames must be empty, %q or %q", LogFramesText, LogFramesHex)
+	case c.Edits.Strategy != "" && c.Edits.Strategy != EditsRepost && c.Edits.Strategy != EditsFollowUp && c.Edits.Strategy != EditsComment:
+		return fmt.Errorf("edits.strategy must be empty, %q, %q or %q", EditsRepost, EditsFollowUp, EditsComment)
+	case c.Bridge.LogStderr && c.Bridge.LogFile == "":
+		return fmt.Errorf("bridge.log_stderr only applies when bridge.log_file is set")
+	case c.Content.CodeBlocks != CodeBlocksPreserve && c.Content.CodeBlocks != CodeBlocksStrip && c.Content.CodeBlocks != CodeBlocksIndent:
+		return fmt.Errorf("content.code_blocks must be %q, %q or %q", CodeBlocksPreserve, CodeBlocksStrip, CodeBlocksIndent)
+	case c.Nostr.RelayLimits != "" && c.Nostr.RelayLimits != RelayLimitsWarn && c.Nostr.RelayLimits != RelayLimitsSkip:
+		return fmt.Errorf("nostr.relay_limits must be empty, %q or %q", RelayLimitsWarn, RelayLimitsSkip)
+	}
+	return nil
+}
+
+// unknownFieldRe matches the yaml error for a key that doesn't exist in the config structure
+var unknownFieldRe = regexp.MustCompile(`field (\S+) not found in type .*`)
+
+// describeYAMLError rewrites unknown key errors, which name an unreadable Go type, into a short
+// message pointing at the misspelled setting
+func describeYAMLError(err error) error {
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		return err
+	}
+
+	messages := make([]string, len(typeErr.Errors))
+	for i, msg := range typeErr.Errors {
+		messages[i] = unknownFieldRe.ReplaceAllString(msg, "unknown setting \"$1\", check it for typos")
+	}
+	return errors.New(strings.Join(messages, "; "))
+}
+
+// normalizeRelayURLs lowercases the scheme and host of each relay URL, strips trailing slashes
+// and removes duplicates while keeping the original order
+func normalizeRelayURLs(relays []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var normalized []string
+
+	for _, relay := range relays {
+		relay = strings.TrimSpace(relay)
+		if relay == "" {
+			continue
+		}
+
+		u, err := url.Parse(relay)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid relay URL %q", relay)
+		}
+		u.Scheme = strings.ToLower(u.Scheme)
+		u.Host = strings.ToLower(u.Host)
+		u.Path = strings.TrimRight(u.Path, "/")
+
+		relayURL := u.String()
+		if seen[relayURL] {
+			continue
+		}
+		seen[relayURL] = true
+		normalized = append(normalized, relayURL)
+	}
+
+	return normalized, nil
+}